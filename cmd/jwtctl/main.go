@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dyegopenha/jwt-playground/internal/app/jwtctl"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/gracefulshutdown"
+)
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, jwtctl.Usage)
+		return
+	}
+
+	ctx := gracefulshutdown.WithShutdownSignal(context.Background())
+
+	app := jwtctl.New()
+	if err := app.Run(ctx, args); err != nil {
+		fmt.Fprintf(os.Stderr, "jwtctl: %v\n", err)
+		if errors.Is(err, jwtctl.ErrUsage) {
+			fmt.Fprint(os.Stderr, "\n"+jwtctl.Usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
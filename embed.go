@@ -6,3 +6,6 @@ import (
 
 //go:embed .env*
 var Env embed.FS
+
+//go:embed web/playground
+var Playground embed.FS
@@ -0,0 +1,51 @@
+package jwtctl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// env prints the effective environment, after defaults were applied, in
+// the format of the .env file.
+func (a *App) env(args []string) error {
+	fs := a.flagSet("env")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(a.e.Redacted()).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" || !t.Field(i).IsExported() {
+			continue
+		}
+		fmt.Fprintf(a.out, "%s=%s\n", name, formatEnvValue(v.Field(i).Interface()))
+	}
+	return nil
+}
+
+// formatEnvValue formats a value the way the environment parses it.
+func formatEnvValue(value any) string {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]string:
+		pairs := make([]string, 0, len(v))
+		for k, val := range v {
+			pairs = append(pairs, k+"="+val)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	case string:
+		// Multi-line values such as PEM keys are written on one line.
+		return strings.ReplaceAll(v, "\n", `\n`)
+	default:
+		return fmt.Sprint(v)
+	}
+}
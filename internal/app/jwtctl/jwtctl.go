@@ -0,0 +1,146 @@
+package jwtctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// Usage describes every command. It is printed without loading the
+// environment, so that help works without a reachable cache.
+const Usage = `usage: jwtctl <command> [arguments]
+
+Tokens are read from the argument or, when it is missing or "-", from
+standard input.
+
+commands:
+  decode [token]                      print a token's header and claims without verifying them
+  verify [token]                      verify a token step by step, including revocation
+  mint [flags]                        mint a token for testing (development and test only)
+  keys generate [-alg ALG]            generate a signing key for the environment
+  keys rotate                         add a new key to the shared keyring now
+  sessions list <user-id>             list a user's active refresh sessions
+  sessions revoke <user-id> <id>      revoke one refresh session
+  env                                 print the effective environment, secrets redacted
+`
+
+// ErrUsage is returned for unknown commands and invalid arguments.
+var ErrUsage = errors.New("invalid usage")
+
+// errRejected is returned by verify when the token is not accepted, so the
+// command exits with a non-zero status.
+var errRejected = errors.New("token rejected")
+
+// App runs jwtctl commands against the configured keys and cache.
+type App struct {
+	e   *env.Env
+	j   *jwtutil.JWTUtil
+	kr  *jwtutil.KeyRotator
+	vac *usecase.VerifyAccessTokenUseCase
+	mtc *usecase.MintTokenUseCase
+	lsc *usecase.ListSessionsUseCase
+	rsc *usecase.RevokeSessionUseCase
+
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+func newApp(
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+	kr *jwtutil.KeyRotator,
+	vac *usecase.VerifyAccessTokenUseCase,
+	mtc *usecase.MintTokenUseCase,
+	lsc *usecase.ListSessionsUseCase,
+	rsc *usecase.RevokeSessionUseCase,
+) *App {
+	return &App{
+		e:   e,
+		j:   j,
+		kr:  kr,
+		vac: vac,
+		mtc: mtc,
+		lsc: lsc,
+		rsc: rsc,
+		in:  os.Stdin,
+		out: os.Stdout,
+		err: os.Stderr,
+	}
+}
+
+// Run runs the command named by args[0].
+func (a *App) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "decode":
+		return a.decode(ctx, rest)
+	case "verify":
+		return a.verify(ctx, rest)
+	case "mint":
+		return a.mint(ctx, rest)
+	case "keys":
+		return a.keys(ctx, rest)
+	case "sessions":
+		return a.sessions(ctx, rest)
+	case "env":
+		return a.env(rest)
+	default:
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, cmd)
+	}
+}
+
+// flagSet returns a flag set that reports errors instead of exiting.
+func (a *App) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("jwtctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.err)
+	return fs
+}
+
+// parse parses the flags of a command, which takes at most maxArgs
+// positional arguments.
+func parse(fs *flag.FlagSet, args []string, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if fs.NArg() > maxArgs {
+		return fmt.Errorf("%w: unexpected argument %q", ErrUsage, fs.Arg(maxArgs))
+	}
+	return nil
+}
+
+// token returns the token passed as the only argument, or read from
+// standard input.
+func (a *App) token(fs *flag.FlagSet) (string, error) {
+	if arg := fs.Arg(0); arg != "" && arg != "-" {
+		return arg, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(a.in, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("%w: no token given", ErrUsage)
+	}
+	return token, nil
+}
+
+func (a *App) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,74 @@
+package jwtctl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func (a *App) keys(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: keys needs a subcommand", ErrUsage)
+	}
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "generate":
+		return a.generateKey(rest)
+	case "rotate":
+		return a.rotateKey(ctx, rest)
+	default:
+		return fmt.Errorf("%w: unknown keys subcommand %q", ErrUsage, cmd)
+	}
+}
+
+// generateKey prints a new key as environment variables, followed by its
+// public JWK for asymmetric algorithms.
+func (a *App) generateKey(args []string) error {
+	fs := a.flagSet("keys generate")
+	alg := fs.String("alg", a.e.JWTAlgorithm, "signing algorithm")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	method := jwt.GetSigningMethod(*alg)
+	if method == nil {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrUsage, *alg)
+	}
+
+	k, encoded, err := jwtutil.GenerateEncodedKey(method)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "JWT_ALGORITHM=%s\n", method.Alg())
+	fmt.Fprintf(a.out, "JWT_KEY_ID=%s\n", k.ID)
+	jwk, ok := k.JWK()
+	if !ok {
+		fmt.Fprintf(a.out, "HMAC_KEY=%s\n", encoded)
+		return nil
+	}
+	fmt.Fprintf(a.out, "JWT_PRIVATE_KEY=%q\n\n", encoded)
+	return a.printJSON(jwk)
+}
+
+// rotateKey adds a key to the keyring shared by the servers, which start
+// signing with it once it activates.
+func (a *App) rotateKey(ctx context.Context, args []string) error {
+	fs := a.flagSet("keys rotate")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	kid, err := a.kr.Rotate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rotate keys: %w", err)
+	}
+	fmt.Fprintf(
+		a.out,
+		"generated signing key %s, active in %s\n",
+		kid,
+		2*a.e.JWTKeySyncInterval,
+	)
+	return nil
+}
@@ -0,0 +1,79 @@
+package jwtctl
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+func (a *App) sessions(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: sessions needs a subcommand", ErrUsage)
+	}
+
+	switch cmd, rest := args[0], args[1:]; cmd {
+	case "list":
+		return a.listSessions(ctx, rest)
+	case "revoke":
+		return a.revokeSession(ctx, rest)
+	default:
+		return fmt.Errorf("%w: unknown sessions subcommand %q", ErrUsage, cmd)
+	}
+}
+
+func (a *App) listSessions(ctx context.Context, args []string) error {
+	fs := a.flagSet("sessions list")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: sessions list needs a user id", ErrUsage)
+	}
+
+	sessions, err := a.lsc.Execute(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tROLE\tCREATED\tLAST USED\tEXPIRES\tIP\tBOUND TO\tUSER AGENT")
+	for _, s := range sessions {
+		bound := "-"
+		switch {
+		case s.JKT != "":
+			bound = "dpop:" + s.JKT
+		case s.X5TS256 != "":
+			bound = "mtls:" + s.X5TS256
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%q\n",
+			s.ID,
+			s.Role,
+			s.CreatedAt.Format(time.RFC3339),
+			s.LastUsedAt.Format(time.RFC3339),
+			s.ExpiresAt.Format(time.RFC3339),
+			s.IP,
+			bound,
+			s.UserAgent,
+		)
+	}
+	return tw.Flush()
+}
+
+func (a *App) revokeSession(ctx context.Context, args []string) error {
+	fs := a.flagSet("sessions revoke")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: sessions revoke needs a user id and a session id", ErrUsage)
+	}
+
+	if err := a.rsc.Execute(ctx, fs.Arg(0), fs.Arg(1)); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "revoked session %s\n", fs.Arg(1))
+	return nil
+}
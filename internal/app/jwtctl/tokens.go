@@ -0,0 +1,133 @@
+package jwtctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+func (a *App) decode(ctx context.Context, args []string) error {
+	fs := a.flagSet("decode")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	token, err := a.token(fs)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := a.j.Decode(ctx, token)
+	if err != nil {
+		return err
+	}
+	return a.printJSON(decoded)
+}
+
+// verify prints every verification step, like the /debug/verify endpoint,
+// and fails when the token is not accepted.
+func (a *App) verify(ctx context.Context, args []string) error {
+	fs := a.flagSet("verify")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	token, err := a.token(fs)
+	if err != nil {
+		return err
+	}
+
+	steps, claims, err := a.j.Explain(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	revocation := jwtutil.VerificationStep{Name: "revocation", Skipped: true}
+	if claims != nil {
+		_, err := a.vac.Execute(ctx, token)
+		if err != nil && !errors.Is(err, usecase.ErrTokenRevoked) {
+			return err
+		}
+		revocation = jwtutil.VerificationStep{Name: "revocation", OK: err == nil}
+		if err != nil {
+			revocation.Error = err.Error()
+		}
+	}
+	steps = append(steps, revocation)
+
+	for _, s := range steps {
+		switch {
+		case s.Skipped:
+			fmt.Fprintf(a.out, "skipped  %s\n", s.Name)
+		case s.OK:
+			fmt.Fprintf(a.out, "ok       %s\n", s.Name)
+		default:
+			fmt.Fprintf(a.out, "FAILED   %s: %s\n", s.Name, s.Error)
+		}
+	}
+	if !revocation.OK {
+		return errRejected
+	}
+	return nil
+}
+
+// claimFlags collects repeated -claim name=value flags. Values are parsed as
+// JSON when possible and kept as strings otherwise.
+type claimFlags map[string]any
+
+func (c claimFlags) String() string {
+	return ""
+}
+
+func (c claimFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid claim %q, expected name=value", s)
+	}
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+	c[name] = v
+	return nil
+}
+
+func (a *App) mint(ctx context.Context, args []string) error {
+	spec := jwtutil.MintSpec{Claims: claimFlags{}}
+	var audience string
+
+	fs := a.flagSet("mint")
+	fs.StringVar(&spec.Subject, "sub", "", "subject (required)")
+	fs.StringVar(&spec.Role, "role", "user", "role claim")
+	fs.StringVar(&spec.Issuer, "iss", "", "issuer (defaults to JWT_ISSUER)")
+	fs.StringVar(&audience, "aud", "", "comma-separated audience (defaults to JWT_AUDIENCE)")
+	fs.DurationVar(&spec.TTL, "ttl", 0, "lifetime, negative for an expired token (defaults to ACCESS_TOKEN_TTL)")
+	fs.Var(claimFlags(spec.Claims), "claim", "extra claim as name=value, repeatable")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if spec.Subject == "" {
+		return fmt.Errorf("%w: -sub is required", ErrUsage)
+	}
+	for _, aud := range strings.Split(audience, ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			spec.Audience = append(spec.Audience, aud)
+		}
+	}
+
+	token, expiresAt, err := a.mtc.Execute(ctx, spec)
+	if errors.Is(err, usecase.ErrDebugDisabled) {
+		return fmt.Errorf("minting is only available in development and test, not %s", a.e.Environment)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(a.out, token)
+	fmt.Fprintf(a.err, "expires at %s\n", expiresAt.Format(time.RFC3339))
+	return nil
+}
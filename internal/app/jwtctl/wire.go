@@ -0,0 +1,37 @@
+//go:build wireinject
+// +build wireinject
+
+package jwtctl
+
+import (
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/validator"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache/redis"
+	"github.com/google/wire"
+)
+
+func New() *App {
+	wire.Build(
+		env.NewEnv,
+
+		jwtutil.NewJWTUtil,
+		jwtutil.NewKeyRotator,
+		wire.Bind(new(validator.Validator), new(*validator.Validation)),
+		validator.New,
+
+		wire.Bind(new(cache.Cache), new(*redis.Redis)),
+		redis.NewRedis,
+
+		usecase.NewVerifyAccessTokenUseCase,
+		usecase.NewMintTokenUseCase,
+		usecase.NewListSessionsUseCase,
+		usecase.NewRevokeSessionUseCase,
+
+		newApp,
+	)
+
+	return &App{}
+}
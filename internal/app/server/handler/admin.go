@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+type AdminHandler struct {
+	rac *usecase.RevokeAccessTokenUseCase
+	ruc *usecase.RevokeUserTokensUseCase
+	rlc *usecase.RevokeAllTokensUseCase
+}
+
+func NewAdminHandler(
+	rac *usecase.RevokeAccessTokenUseCase,
+	ruc *usecase.RevokeUserTokensUseCase,
+	rlc *usecase.RevokeAllTokensUseCase,
+) *AdminHandler {
+	return &AdminHandler{
+		rac: rac,
+		ruc: ruc,
+		rlc: rlc,
+	}
+}
+
+// RevokeToken revokes a single access token until it expires.
+func (h *AdminHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.rac.Execute(r.Context(), body.Token); err != nil {
+		var verr *jwtutil.VerificationError
+		if errors.As(err, &verr) {
+			http.Error(w, "invalid token", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeUserTokens invalidates every token issued to a user so far.
+func (h *AdminHandler) RevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.UserID == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.ruc.Execute(r.Context(), body.UserID); err != nil {
+		http.Error(w, "failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllTokens invalidates every token issued so far, for every user.
+func (h *AdminHandler) RevokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.rlc.Execute(r.Context()); err != nil {
+		http.Error(w, "failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
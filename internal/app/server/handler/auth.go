@@ -2,27 +2,86 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/dyegopenha/jwt-playground/internal/app/server/middleware"
 	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
 	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 )
 
 type AuthHandler struct {
 	e   *env.Env
 	sic *usecase.SignInUseCase
 	ruc *usecase.RefreshUseCase
+	vdc *usecase.VerifyDPoPProofUseCase
 }
 
 func NewAuthHandler(
 	e *env.Env,
 	sic *usecase.SignInUseCase,
 	ruc *usecase.RefreshUseCase,
+	vdc *usecase.VerifyDPoPProofUseCase,
 ) *AuthHandler {
 	return &AuthHandler{
 		e:   e,
 		sic: sic,
 		ruc: ruc,
+		vdc: vdc,
+	}
+}
+
+// confirmation collects the keys the client proved possession of: the key
+// of its DPoP proof and its TLS client certificate, if any. It writes the
+// error response itself and reports false when the proof is rejected.
+func confirmation(
+	w http.ResponseWriter,
+	r *http.Request,
+	vdc *usecase.VerifyDPoPProofUseCase,
+) (jwtutil.Confirmation, bool) {
+	jkt, err := middleware.DPoPProof(r.Context(), vdc, r, "")
+	if err != nil {
+		if middleware.IsDPoPError(err) {
+			log.Printf("rejected dpop proof: %v", err)
+			http.Error(w, "invalid dpop proof", http.StatusBadRequest)
+			return jwtutil.Confirmation{}, false
+		}
+		log.Printf("failed to verify dpop proof: %v", err)
+		http.Error(w, "failed to verify dpop proof", http.StatusInternalServerError)
+		return jwtutil.Confirmation{}, false
+	}
+	return jwtutil.Confirmation{
+		JKT:     jkt,
+		X5TS256: middleware.PeerCertificateThumbprint(r),
+	}, true
+}
+
+// clientInfo describes the client for its refresh session. The IP is the
+// peer address of the connection; forwarding headers are not trusted.
+func clientInfo(r *http.Request) entity.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return entity.ClientInfo{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// refreshCookie carries the refresh token until its session expires. Since
+// refreshing does not extend the session, neither does the cookie.
+func refreshCookie(refreshToken string, expiresAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:   refreshCookieName,
+		Value:  refreshToken,
+		Path:   "/",
+		MaxAge: max(int(time.Until(expiresAt).Seconds()), 1),
 	}
 }
 
@@ -36,25 +95,28 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessTok, refreshTok, err := h.sic.Execute(
+	cnf, ok := confirmation(w, r, h.vdc)
+	if !ok {
+		return
+	}
+
+	accessTok, refreshTok, expiresAt, err := h.sic.Execute(
 		r.Context(),
 		creds.Email,
 		creds.Password,
+		cnf,
+		clientInfo(r),
 	)
 	if err != nil {
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:   refreshCookieName,
-		Value:  refreshTok,
-		Path:   "/",
-		MaxAge: int(h.e.RefreshTokenTTL.Seconds()),
-	})
+	http.SetCookie(w, refreshCookie(refreshTok, expiresAt))
 
-	if err := json.NewEncoder(w).Encode(map[string]string{
+	if err := json.NewEncoder(w).Encode(map[string]any{
 		"access_token": accessTok,
+		"expires_in":   int(h.e.AccessTokenTTL.Seconds()),
 	}); err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		return
@@ -68,22 +130,42 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cnf, ok := confirmation(w, r, h.vdc)
+	if !ok {
+		return
+	}
+
 	refreshTok := cookie.Value
-	accessTok, newRefreshTok, err := h.ruc.Execute(r.Context(), refreshTok)
+	accessTok, newRefreshTok, expiresAt, err := h.ruc.Execute(
+		r.Context(),
+		refreshTok,
+		cnf,
+		clientInfo(r),
+	)
+	if errors.Is(err, usecase.ErrDPoPKeyMismatch) {
+		http.Error(w, "invalid dpop proof", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, usecase.ErrCertificateMismatch) {
+		http.Error(w, "invalid client certificate", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, usecase.ErrInvalidRefreshToken) ||
+		errors.Is(err, usecase.ErrRefreshTokenReused) ||
+		errors.Is(err, usecase.ErrTokenRevoked) {
+		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to refresh token", http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:   refreshCookieName,
-		Value:  newRefreshTok,
-		Path:   "/",
-		MaxAge: int(h.e.RefreshTokenTTL.Seconds()),
-	})
+	http.SetCookie(w, refreshCookie(newRefreshTok, expiresAt))
 
-	if err := json.NewEncoder(w).Encode(map[string]string{
+	if err := json.NewEncoder(w).Encode(map[string]any{
 		"access_token": accessTok,
+		"expires_in":   int(h.e.AccessTokenTTL.Seconds()),
 	}); err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
 		return
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+)
+
+type CapabilityHandler struct {
+	icc *usecase.IssueCapabilityUseCase
+}
+
+func NewCapabilityHandler(icc *usecase.IssueCapabilityUseCase) *CapabilityHandler {
+	return &CapabilityHandler{
+		icc: icc,
+	}
+}
+
+// Issue mints a capability token for the current user, optionally
+// restricted by the caveats in the request body. Holders can restrict it
+// further offline before handing it to someone else.
+func (h *CapabilityHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Caveats []string `json:"caveats"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.icc.Execute(CurrentUser(r), body.Caveats)
+	switch {
+	case errors.Is(err, usecase.ErrCapabilitiesDisabled):
+		http.Error(w, "capability tokens are disabled", http.StatusNotFound)
+		return
+	case errors.Is(err, usecase.ErrRestrictedToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, usecase.ErrInvalidCaveat):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("failed to issue capability: %v", err)
+		http.Error(w, "failed to issue capability", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"capability": token,
+	}); err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+}
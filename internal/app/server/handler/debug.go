@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+type DebugHandler struct {
+	dtc *usecase.DecodeTokenUseCase
+	etc *usecase.ExplainTokenUseCase
+	mtc *usecase.MintTokenUseCase
+}
+
+func NewDebugHandler(
+	dtc *usecase.DecodeTokenUseCase,
+	etc *usecase.ExplainTokenUseCase,
+	mtc *usecase.MintTokenUseCase,
+) *DebugHandler {
+	return &DebugHandler{
+		dtc: dtc,
+		etc: etc,
+		mtc: mtc,
+	}
+}
+
+// Decode returns a token's header and claims without verifying them.
+func (h *DebugHandler) Decode(w http.ResponseWriter, r *http.Request) {
+	token, ok := readDebugToken(w, r)
+	if !ok {
+		return
+	}
+
+	decoded, err := h.dtc.Execute(r.Context(), token)
+	if err != nil {
+		var verr *jwtutil.VerificationError
+		if errors.As(err, &verr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeDebugError(w, err, "failed to decode token")
+		return
+	}
+
+	writeDebugResponse(w, decoded)
+}
+
+// Verify reports every verification step for an access token, with the
+// exact reason of each failure.
+func (h *DebugHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	token, ok := readDebugToken(w, r)
+	if !ok {
+		return
+	}
+
+	steps, valid, err := h.etc.Execute(r.Context(), token)
+	if err != nil {
+		writeDebugError(w, err, "failed to verify token")
+		return
+	}
+
+	writeDebugResponse(w, map[string]any{
+		"valid": valid,
+		"steps": steps,
+	})
+}
+
+// Mint signs a token with arbitrary claims and TTL.
+func (h *DebugHandler) Mint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Subject  string         `json:"subject"`
+		Role     string         `json:"role"`
+		Issuer   string         `json:"issuer"`
+		Audience []string       `json:"audience"`
+		TTL      string         `json:"ttl"`
+		Claims   map[string]any `json:"claims"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	token, expiresAt, err := h.mtc.Execute(r.Context(), jwtutil.MintSpec{
+		Subject:  body.Subject,
+		Role:     body.Role,
+		Issuer:   body.Issuer,
+		Audience: body.Audience,
+		TTL:      ttl,
+		Claims:   body.Claims,
+	})
+	if err != nil {
+		writeDebugError(w, err, "failed to mint token")
+		return
+	}
+
+	writeDebugResponse(w, map[string]string{
+		"access_token": token,
+		"expires_at":   expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func readDebugToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return "", false
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	return body.Token, true
+}
+
+func writeDebugError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, usecase.ErrDebugDisabled) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("%s: %v", msg, err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
+
+func writeDebugResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -15,3 +15,10 @@ func CurrentUser(r *http.Request) *jwtutil.Claims {
 	}
 	return nil
 }
+
+// CurrentClient returns the ID of the client authenticated by
+// RequireClient.
+func CurrentClient(r *http.Request) string {
+	id, _ := r.Context().Value(middleware.ClientIDKey).(string)
+	return id
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+)
+
+type IntrospectionHandler struct {
+	itc *usecase.IntrospectTokenUseCase
+}
+
+func NewIntrospectionHandler(
+	itc *usecase.IntrospectTokenUseCase,
+) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		itc: itc,
+	}
+}
+
+// Introspect implements the RFC 7662 token introspection endpoint. The
+// token is read from the form-encoded "token" parameter; token_type_hint is
+// accepted but ignored since only access tokens can be introspected.
+func (h *IntrospectionHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, active, err := h.itc.Execute(r.Context(), r.PostForm.Get("token"))
+	if err != nil {
+		log.Printf("failed to introspect token: %v", err)
+		http.Error(w, "failed to introspect token", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]any{}
+	if active {
+		// Expose the same claims a self-contained token would carry.
+		b, err := json.Marshal(claims)
+		if err == nil {
+			err = json.Unmarshal(b, &resp)
+		}
+		if err != nil {
+			http.Error(w, "error writing response", http.StatusInternalServerError)
+			return
+		}
+		// RFC 9449 section 6.2: DPoP-bound tokens are reported as such, so
+		// resource servers know to require a proof.
+		resp["token_type"] = "Bearer"
+		if claims.Confirmation != nil && claims.Confirmation.JKT != "" {
+			resp["token_type"] = "DPoP"
+		}
+	}
+	resp["active"] = active
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// jwksMaxAge is how long, in seconds, clients may cache the key set.
+const jwksMaxAge = "300"
+
+type JWKSHandler struct {
+	j *jwtutil.JWTUtil
+}
+
+func NewJWKSHandler(j *jwtutil.JWTUtil) *JWKSHandler {
+	return &JWKSHandler{
+		j: j,
+	}
+}
+
+// JWKS serves the access-token verification keys as an RFC 7517 JWK Set.
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(h.j.JWKS())
+	if err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("Cache-Control", "public, max-age="+jwksMaxAge)
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	if _, err := w.Write(body); err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+}
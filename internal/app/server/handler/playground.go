@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+
+	root "github.com/dyegopenha/jwt-playground"
+)
+
+// playgroundPath is where the playground UI is served.
+const playgroundPath = "/playground/"
+
+type PlaygroundHandler struct {
+	files http.Handler
+}
+
+func NewPlaygroundHandler() *PlaygroundHandler {
+	files, err := fs.Sub(root.Playground, "web/playground")
+	if err != nil {
+		log.Fatalf("failed to load playground: %v", err)
+	}
+
+	return &PlaygroundHandler{
+		files: http.StripPrefix(
+			playgroundPath,
+			http.FileServer(http.FS(files)),
+		),
+	}
+}
+
+// Playground serves the embedded single-page UI that walks through the
+// sign-in, token inspection, protected route and refresh flow.
+func (h *PlaygroundHandler) Playground(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(
+		"Content-Security-Policy",
+		"default-src 'self'; frame-ancestors 'none'",
+	)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	h.files.ServeHTTP(w, r)
+}
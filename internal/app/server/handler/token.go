@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+)
+
+const grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// tokenExchangeErrors are the usecase errors reported to the client as an
+// OAuth error response.
+var tokenExchangeErrors = []error{
+	usecase.ErrInvalidRequest,
+	usecase.ErrInvalidGrant,
+	usecase.ErrInvalidTarget,
+	usecase.ErrInvalidScope,
+	usecase.ErrUnauthorizedClient,
+}
+
+type TokenHandler struct {
+	etc *usecase.ExchangeTokenUseCase
+	vdc *usecase.VerifyDPoPProofUseCase
+}
+
+func NewTokenHandler(
+	etc *usecase.ExchangeTokenUseCase,
+	vdc *usecase.VerifyDPoPProofUseCase,
+) *TokenHandler {
+	return &TokenHandler{
+		etc: etc,
+		vdc: vdc,
+	}
+}
+
+// Token implements the RFC 8693 token exchange grant. The client must have
+// been authenticated by RequireClient. Sender-constrained subject and actor
+// tokens are only exchanged with a DPoP proof or TLS client certificate
+// for the key they are bound to.
+func (h *TokenHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid request body")
+		return
+	}
+
+	form := r.PostForm
+	if form.Get("grant_type") != grantTypeTokenExchange {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	if form.Get("subject_token") == "" || form.Get("subject_token_type") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "subject_token is required")
+		return
+	}
+	if t := form.Get("requested_token_type"); t != "" && t != usecase.TokenTypeAccessToken {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "unsupported requested_token_type")
+		return
+	}
+
+	cnf, ok := confirmation(w, r, h.vdc)
+	if !ok {
+		return
+	}
+
+	res, err := h.etc.Execute(r.Context(), usecase.TokenExchangeRequest{
+		ClientID:         CurrentClient(r),
+		Client:           clientInfo(r),
+		Confirmation:     cnf,
+		SubjectToken:     form.Get("subject_token"),
+		SubjectTokenType: form.Get("subject_token_type"),
+		ActorToken:       form.Get("actor_token"),
+		ActorTokenType:   form.Get("actor_token_type"),
+		Audience:         form["audience"],
+		Scope:            strings.Fields(form.Get("scope")),
+	})
+	if err != nil {
+		for _, code := range tokenExchangeErrors {
+			if errors.Is(err, code) {
+				log.Printf("rejected token exchange: %v", err)
+				writeOAuthError(w, http.StatusBadRequest, code.Error(), "")
+				return
+			}
+		}
+		log.Printf("failed to exchange token: %v", err)
+		http.Error(w, "failed to exchange token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"access_token":      res.AccessToken,
+		"issued_token_type": usecase.TokenTypeAccessToken,
+		"token_type":        "Bearer",
+		"expires_in":        int(res.ExpiresIn.Seconds()),
+		"scope":             res.Scope,
+	}); err != nil {
+		http.Error(w, "error writing response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeOAuthError writes an RFC 6749 section 5.2 error response.
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
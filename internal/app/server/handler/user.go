@@ -15,7 +15,7 @@ func (uh *UserHandler) Profile(w http.ResponseWriter, r *http.Request) {
 	user := CurrentUser(r)
 
 	if err := json.NewEncoder(w).Encode(map[string]string{
-		"id":   user.Issuer,
+		"id":   user.Subject,
 		"role": user.Role,
 	}); err != nil {
 		http.Error(w, "error writing response", http.StatusInternalServerError)
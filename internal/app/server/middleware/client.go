@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// RequireClient only lets through resource servers and backend services
+// that authenticate with HTTP Basic credentials listed in OAUTH_CLIENTS. The
+// client ID is attached to the request context.
+func (m *Middleware) RequireClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || !m.validClient(id, secret) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jwt-playground"`)
+			http.Error(w, "invalid client credentials", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ClientIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validClient compares secrets in constant time. Unknown clients are still
+// compared against an empty secret so they take as long as known ones.
+func (m *Middleware) validClient(id, secret string) bool {
+	want, known := m.e.OAuthClients[id]
+	got := sha256.Sum256([]byte(secret))
+	expected := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(got[:], expected[:]) == 1 && known
+}
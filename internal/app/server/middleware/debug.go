@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// DebugOnly hides a handler, as if the route did not exist, unless the
+// debugging endpoints are enabled.
+func (m *Middleware) DebugOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.e.DebugEndpointsEnabled() {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// errMissingDPoPProof is returned when a DPoP-bound request carries no
+// proof, or more than one.
+var errMissingDPoPProof = errors.New("missing dpop proof")
+
+// RequestURL reconstructs the URL a request was sent to, without query or
+// fragment, for comparison with the htu claim of a DPoP proof.
+func RequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.EscapedPath()
+}
+
+// DPoPProof verifies the request's DPoP header, if any, and returns the
+// thumbprint of the key that signed it. accessToken is empty on the token
+// endpoints. An empty thumbprint with a nil error means no proof was sent.
+func DPoPProof(
+	ctx context.Context,
+	vdc *usecase.VerifyDPoPProofUseCase,
+	r *http.Request,
+	accessToken string,
+) (string, error) {
+	proofs := r.Header.Values("DPoP")
+	switch len(proofs) {
+	case 0:
+		return "", nil
+	case 1:
+	default:
+		return "", errMissingDPoPProof
+	}
+
+	p, err := vdc.Execute(ctx, proofs[0], r.Method, RequestURL(r), accessToken)
+	if err != nil {
+		return "", err
+	}
+	return p.JKT, nil
+}
+
+// IsDPoPError reports whether err means the client sent a bad DPoP proof,
+// as opposed to the proof not being checkable.
+func IsDPoPError(err error) bool {
+	var verr *jwtutil.VerificationError
+	return errors.As(err, &verr) ||
+		errors.Is(err, errMissingDPoPProof) ||
+		errors.Is(err, usecase.ErrDPoPProofReplayed) ||
+		errors.Is(err, usecase.ErrDPoPKeyMismatch)
+}
+
+// checkDPoP enforces the token's key binding. Tokens bound to a DPoP key
+// must be presented with the DPoP scheme and a proof from that key, and the
+// DPoP scheme may only be used with bound tokens.
+func (m *Middleware) checkDPoP(
+	r *http.Request,
+	scheme, accessToken string,
+	claims *jwtutil.Claims,
+) error {
+	var jkt string
+	if claims.Confirmation != nil {
+		jkt = claims.Confirmation.JKT
+	}
+	if jkt == "" {
+		if scheme == dpopScheme {
+			return usecase.ErrDPoPKeyMismatch
+		}
+		return nil
+	}
+	if scheme != dpopScheme {
+		return errMissingDPoPProof
+	}
+
+	proofJKT, err := DPoPProof(r.Context(), m.vdc, r, accessToken)
+	if err != nil {
+		return err
+	}
+	if proofJKT == "" {
+		return errMissingDPoPProof
+	}
+	if proofJKT != jkt {
+		return usecase.ErrDPoPKeyMismatch
+	}
+	return nil
+}
@@ -2,35 +2,101 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 )
 
 type ctxKey string
 
 const (
-	ClaimsKey ctxKey = "jwt_claims"
+	ClaimsKey   ctxKey = "jwt_claims"
+	ClientIDKey ctxKey = "client_id"
+)
+
+const (
+	bearerScheme = "Bearer"
+	dpopScheme   = "DPoP"
 )
 
-// AuthMiddleware verifies the Authorization header ("Bearer <token>").
+// AuthMiddleware verifies the Authorization header ("Bearer <token>", or
+// "DPoP <token>" with a DPoP proof for sender-constrained tokens).
+// Capability tokens are accepted as bearer tokens once their caveats hold
+// for the request method, path and current time.
 // On success it attaches the jwtutil.Claims to the request context so that
 // downstream handlers can retrieve the current user via CurrentUser.
 func (m *Middleware) JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if !strings.HasPrefix(auth, "Bearer ") {
+		scheme, raw, ok := parseAuthorization(r.Header.Get("Authorization"))
+		if !ok {
 			http.Error(w, "missing bearer token", http.StatusUnauthorized)
 			return
 		}
-		raw := strings.TrimPrefix(auth, "Bearer ")
 
-		claims, err := m.j.ParseAndVerify(raw)
+		claims, err := m.verifyToken(r, raw)
 		if err != nil {
+			var verr *jwtutil.VerificationError
+			if !errors.As(err, &verr) && !errors.Is(err, usecase.ErrTokenRevoked) {
+				log.Printf("failed to verify access token: %v", err)
+				http.Error(w, "failed to verify token", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("rejected access token: %v", err)
 			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
+		if err := checkCertificate(r, claims); err != nil {
+			log.Printf("rejected access token: %v", err)
+			http.Error(w, "invalid client certificate", http.StatusUnauthorized)
+			return
+		}
+
+		if err := m.checkDPoP(r, scheme, raw, claims); err != nil {
+			if !IsDPoPError(err) {
+				log.Printf("failed to verify dpop proof: %v", err)
+				http.Error(w, "failed to verify token", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("rejected dpop proof: %v", err)
+			w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			http.Error(w, "invalid dpop proof", http.StatusUnauthorized)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), ClaimsKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// verifyToken verifies an access token or, for a capability token, its
+// caveats against the request.
+func (m *Middleware) verifyToken(r *http.Request, raw string) (*jwtutil.Claims, error) {
+	if !jwtutil.IsCapability(raw) {
+		return m.vac.Execute(r.Context(), raw)
+	}
+	return m.vcc.Execute(r.Context(), raw, jwtutil.CapabilityRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Time:   time.Now(),
+	})
+}
+
+// parseAuthorization splits an Authorization header into its Bearer or DPoP
+// scheme and token. The token must be a single non-empty field.
+func parseAuthorization(header string) (scheme, token string, ok bool) {
+	scheme, token, ok = strings.Cut(header, " ")
+	if !ok || (scheme != bearerScheme && scheme != dpopScheme) {
+		return "", "", false
+	}
+	if token == "" || strings.ContainsFunc(token, unicode.IsSpace) {
+		return "", "", false
+	}
+	return scheme, token, true
+}
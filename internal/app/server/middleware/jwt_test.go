@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	memorycache "github.com/dyegopenha/jwt-playground/internal/provider/cache/memory"
+)
+
+func newTestMiddleware(t *testing.T) (*Middleware, *jwtutil.JWTUtil) {
+	t.Helper()
+
+	e := &env.Env{
+		Environment:       env.EnvironmentTest,
+		JWTAlgorithm:      "HS256",
+		HMACKey:           "test-hmac-secret-that-is-long-enough",
+		JWTIssuer:         "jwt-playground",
+		JWTAudience:       []string{"jwt-playground-api"},
+		JWTClaimsMaxBytes: 1024,
+		AccessTokenTTL:    time.Minute,
+		DPoPProofMaxAge:   time.Minute,
+		TokenFormat:       jwtutil.TokenFormatJWT,
+	}
+	c := memorycache.NewMemory()
+	j := jwtutil.NewJWTUtil(e, c)
+	m := NewMiddleware(
+		e,
+		usecase.NewVerifyAccessTokenUseCase(c, j),
+		usecase.NewVerifyDPoPProofUseCase(c, e, j),
+		usecase.NewVerifyCapabilityUseCase(c, j),
+	)
+	return m, j
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	m, j := newTestMiddleware(t)
+	token, err := j.SignAccessToken(
+		context.Background(),
+		jwtutil.TokenSubject{UserID: "1", Role: "user"},
+		time.Minute,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := j.SignAccessToken(
+		context.Background(),
+		jwtutil.TokenSubject{UserID: "1", Role: "user"},
+		-time.Minute,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header []string
+		want   int
+	}{
+		{"valid", []string{"Bearer " + token}, http.StatusOK},
+		{"missing", nil, http.StatusUnauthorized},
+		{"empty", []string{""}, http.StatusUnauthorized},
+		{"scheme only", []string{"Bearer"}, http.StatusUnauthorized},
+		{"scheme and space", []string{"Bearer "}, http.StatusUnauthorized},
+		{"no scheme", []string{token}, http.StatusUnauthorized},
+		{"basic scheme", []string{"Basic " + token}, http.StatusUnauthorized},
+		{"lowercase scheme", []string{"bearer " + token}, http.StatusUnauthorized},
+		{"double space", []string{"Bearer  " + token}, http.StatusUnauthorized},
+		{"tab separator", []string{"Bearer\t" + token}, http.StatusUnauthorized},
+		{"trailing field", []string{"Bearer " + token + " extra"}, http.StatusUnauthorized},
+		{"trailing newline", []string{"Bearer " + token + "\n"}, http.StatusUnauthorized},
+		{"two tokens", []string{"Bearer " + token + "," + token}, http.StatusUnauthorized},
+		{"tampered", []string{"Bearer " + token + "x"}, http.StatusUnauthorized},
+		{"expired", []string{"Bearer " + expired}, http.StatusUnauthorized},
+		{"oversized", []string{"Bearer " + strings.Repeat("a", 64<<10)}, http.StatusUnauthorized},
+		{"dpop scheme without proof", []string{"DPoP " + token}, http.StatusUnauthorized},
+		{"bearer with dpop scheme name", []string{"DPoP" + token}, http.StatusUnauthorized},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(ClaimsKey).(*jwtutil.Claims); !ok {
+			t.Error("claims missing from context")
+		}
+	})
+	h := m.JWTMiddleware(next)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, v := range tt.header {
+				req.Header.Add("Authorization", v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseAuthorization(f *testing.F) {
+	f.Add("Bearer abc.def.ghi")
+	f.Add("DPoP abc")
+	f.Add("Bearer ")
+	f.Add("Bearer  abc")
+	f.Add("bearer abc")
+	f.Add("Basic dXNlcjpwYXNz")
+	f.Add("Bearer a b")
+	f.Add("Bearer abc")
+
+	f.Fuzz(func(t *testing.T, header string) {
+		scheme, token, ok := parseAuthorization(header)
+		if !ok {
+			if scheme != "" || token != "" {
+				t.Fatalf("rejected header returned %q %q", scheme, token)
+			}
+			return
+		}
+		if scheme != bearerScheme && scheme != dpopScheme {
+			t.Fatalf("accepted scheme %q", scheme)
+		}
+		if token == "" || strings.ContainsFunc(token, unicode.IsSpace) {
+			t.Fatalf("accepted token %q", token)
+		}
+		if scheme+" "+token != header {
+			t.Fatalf("%q does not round-trip to %q", header, scheme+" "+token)
+		}
+	})
+}
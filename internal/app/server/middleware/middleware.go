@@ -1,13 +1,27 @@
 package middleware
 
-import "github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+import (
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+)
 
 type Middleware struct {
-	j *jwtutil.JWTUtil
+	e   *env.Env
+	vac *usecase.VerifyAccessTokenUseCase
+	vdc *usecase.VerifyDPoPProofUseCase
+	vcc *usecase.VerifyCapabilityUseCase
 }
 
-func NewMiddleware(j *jwtutil.JWTUtil) *Middleware {
+func NewMiddleware(
+	e *env.Env,
+	vac *usecase.VerifyAccessTokenUseCase,
+	vdc *usecase.VerifyDPoPProofUseCase,
+	vcc *usecase.VerifyCapabilityUseCase,
+) *Middleware {
 	return &Middleware{
-		j: j,
+		e:   e,
+		vac: vac,
+		vdc: vdc,
+		vcc: vcc,
 	}
 }
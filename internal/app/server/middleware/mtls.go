@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/usecase"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// PeerCertificateThumbprint returns the x5t#S256 thumbprint of the verified
+// TLS client certificate of the request, or an empty string when the
+// client presented none.
+func PeerCertificateThumbprint(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return jwtutil.CertificateThumbprint(r.TLS.PeerCertificates[0])
+}
+
+// checkCertificate rejects certificate-bound tokens unless the request was
+// made over a TLS connection authenticated with the same certificate.
+func checkCertificate(r *http.Request, claims *jwtutil.Claims) error {
+	if claims.Confirmation == nil || claims.Confirmation.X5TS256 == "" {
+		return nil
+	}
+	got := PeerCertificateThumbprint(r)
+	if got == "" || subtle.ConstantTimeCompare(
+		[]byte(got),
+		[]byte(claims.Confirmation.X5TS256),
+	) != 1 {
+		return usecase.ErrCertificateMismatch
+	}
+	return nil
+}
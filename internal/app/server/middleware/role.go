@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// RequireRole only lets through requests whose verified claims carry the
+// given role. Tokens obtained through token exchange and capability tokens
+// are refused, so that delegated, impersonated and capability access never
+// grants role-based privileges. It must be chained after JWTMiddleware.
+func (m *Middleware) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := r.Context().Value(ClaimsKey).(*jwtutil.Claims)
+			if !ok || claims.Role != role || claims.Actor != nil || claims.Capability {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -10,9 +10,16 @@ import (
 type Router struct {
 	*http.ServeMux
 
-	m  *middleware.Middleware
-	ah *handler.AuthHandler
-	uh *handler.UserHandler
+	m   *middleware.Middleware
+	ah  *handler.AuthHandler
+	uh  *handler.UserHandler
+	jh  *handler.JWKSHandler
+	adh *handler.AdminHandler
+	ih  *handler.IntrospectionHandler
+	th  *handler.TokenHandler
+	ch  *handler.CapabilityHandler
+	dh  *handler.DebugHandler
+	ph  *handler.PlaygroundHandler
 }
 
 // NewMux assembles the HTTP routes and returns a ready-to-use ServeMux.
@@ -20,6 +27,13 @@ func NewRouter(
 	m *middleware.Middleware,
 	ah *handler.AuthHandler,
 	uh *handler.UserHandler,
+	jh *handler.JWKSHandler,
+	adh *handler.AdminHandler,
+	ih *handler.IntrospectionHandler,
+	th *handler.TokenHandler,
+	ch *handler.CapabilityHandler,
+	dh *handler.DebugHandler,
+	ph *handler.PlaygroundHandler,
 ) *Router {
 	mux := http.NewServeMux()
 
@@ -28,6 +42,13 @@ func NewRouter(
 		m:        m,
 		ah:       ah,
 		uh:       uh,
+		jh:       jh,
+		adh:      adh,
+		ih:       ih,
+		th:       th,
+		ch:       ch,
+		dh:       dh,
+		ph:       ph,
 	}
 }
 
@@ -35,10 +56,61 @@ func (r *Router) Register() {
 	// Public endpoints
 	r.Handle("/sign-in", http.HandlerFunc(r.ah.SignIn))
 	r.Handle("/refresh", http.HandlerFunc(r.ah.Refresh))
+	r.Handle("/.well-known/jwks.json", http.HandlerFunc(r.jh.JWKS))
+	r.Handle("/playground/", http.HandlerFunc(r.ph.Playground))
 
 	// Protected endpoints
 	r.Handle(
 		"/",
 		r.m.JWTMiddleware(http.HandlerFunc(r.uh.Profile)),
 	)
+	r.Handle(
+		"/capabilities",
+		r.m.JWTMiddleware(http.HandlerFunc(r.ch.Issue)),
+	)
+
+	// Resource server endpoints
+	r.Handle(
+		"/introspect",
+		r.m.RequireClient(http.HandlerFunc(r.ih.Introspect)),
+	)
+	r.Handle(
+		"/token",
+		r.m.RequireClient(http.HandlerFunc(r.th.Token)),
+	)
+
+	// Development endpoints, hidden unless DEBUG_ENDPOINTS is set in
+	// development or test
+	r.Handle(
+		"/debug/decode",
+		r.m.DebugOnly(http.HandlerFunc(r.dh.Decode)),
+	)
+	r.Handle(
+		"/debug/verify",
+		r.m.DebugOnly(http.HandlerFunc(r.dh.Verify)),
+	)
+	r.Handle(
+		"/debug/mint",
+		r.m.DebugOnly(http.HandlerFunc(r.dh.Mint)),
+	)
+
+	// Admin endpoints
+	r.Handle(
+		"/admin/revoke",
+		r.m.JWTMiddleware(
+			r.m.RequireRole("admin")(http.HandlerFunc(r.adh.RevokeToken)),
+		),
+	)
+	r.Handle(
+		"/admin/revoke-user",
+		r.m.JWTMiddleware(
+			r.m.RequireRole("admin")(http.HandlerFunc(r.adh.RevokeUserTokens)),
+		),
+	)
+	r.Handle(
+		"/admin/revoke-all",
+		r.m.JWTMiddleware(
+			r.m.RequireRole("admin")(http.HandlerFunc(r.adh.RevokeAllTokens)),
+		),
+	)
 }
@@ -2,41 +2,76 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/dyegopenha/jwt-playground/internal/app/server/router"
 	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 )
 
 type Server struct {
-	e *env.Env
-	r *router.Router
+	e  *env.Env
+	r  *router.Router
+	kr *jwtutil.KeyRotator
 }
 
 func newServer(
 	e *env.Env,
 	r *router.Router,
+	kr *jwtutil.KeyRotator,
 ) *Server {
 	return &Server{
-		e: e,
-		r: r,
+		e:  e,
+		r:  r,
+		kr: kr,
 	}
 }
 
 func (s *Server) Run(ctx context.Context) error {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	if s.kr.Enabled() {
+		log.Printf(
+			"rotating signing keys every %s",
+			s.e.JWTKeyRotationInterval,
+		)
+		// The shared keyring is loaded before listening, so that tokens
+		// signed by other replicas verify from the first request.
+		if err := s.kr.Tick(ctx); err != nil {
+			return fmt.Errorf("failed to sync signing keys: %w", err)
+		}
+		go s.kr.Run(ctx)
+	}
+
+	s.r.Register()
+
 	log.Printf("starting server on port %s", s.e.Port)
 
 	srv := &http.Server{
-		Addr:    ":" + s.e.Port,
-		Handler: s.r.ServeMux,
+		Addr:      ":" + s.e.Port,
+		Handler:   s.r.ServeMux,
+		TLSConfig: tlsConfig,
 	}
 
 	errCh := make(chan error, 1)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil &&
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS(s.e.TLSCertFile, s.e.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil &&
 			err != http.ErrServerClosed {
 			errCh <- fmt.Errorf("listen: %w", err)
 			return
@@ -60,3 +95,31 @@ func (s *Server) Run(ctx context.Context) error {
 		return err
 	}
 }
+
+// tlsConfig returns nil when the server should listen over plain HTTP. With
+// a client CA configured, clients may authenticate with a certificate
+// signed by it; requests without a certificate are still served.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	if s.e.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if s.e.TLSClientCAFile == "" {
+		return cfg, nil
+	}
+
+	pemBytes, err := os.ReadFile(s.e.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.New("client ca file contains no certificates")
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return cfg, nil
+}
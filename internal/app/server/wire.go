@@ -13,6 +13,10 @@ import (
 	"github.com/dyegopenha/jwt-playground/internal/pkg/validator"
 	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
 	"github.com/dyegopenha/jwt-playground/internal/provider/cache/redis"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog/logger"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore/memory"
 	"github.com/google/wire"
 )
 
@@ -21,19 +25,45 @@ func New() *Server {
 		env.NewEnv,
 
 		jwtutil.NewJWTUtil,
+		jwtutil.NewKeyRotator,
 		wire.Bind(new(validator.Validator), new(*validator.Validation)),
 		validator.New,
 
 		wire.Bind(new(cache.Cache), new(*redis.Redis)),
 		redis.NewRedis,
 
+		wire.Bind(new(userstore.UserStore), new(*memory.Memory)),
+		memory.NewMemory,
+
+		wire.Bind(new(securitylog.SecurityLog), new(*logger.Logger)),
+		logger.NewLogger,
+
 		usecase.NewSignInUseCase,
 		usecase.NewRefreshUseCase,
+		usecase.NewVerifyAccessTokenUseCase,
+		usecase.NewRevokeAccessTokenUseCase,
+		usecase.NewRevokeUserTokensUseCase,
+		usecase.NewRevokeAllTokensUseCase,
+		usecase.NewIntrospectTokenUseCase,
+		usecase.NewVerifyDPoPProofUseCase,
+		usecase.NewExchangeTokenUseCase,
+		usecase.NewVerifyCapabilityUseCase,
+		usecase.NewIssueCapabilityUseCase,
+		usecase.NewDecodeTokenUseCase,
+		usecase.NewExplainTokenUseCase,
+		usecase.NewMintTokenUseCase,
 
 		middleware.NewMiddleware,
 
 		handler.NewAuthHandler,
 		handler.NewUserHandler,
+		handler.NewJWKSHandler,
+		handler.NewAdminHandler,
+		handler.NewIntrospectionHandler,
+		handler.NewTokenHandler,
+		handler.NewCapabilityHandler,
+		handler.NewDebugHandler,
+		handler.NewPlaygroundHandler,
 
 		router.NewRouter,
 		newServer,
@@ -15,6 +15,8 @@ import (
 	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 	"github.com/dyegopenha/jwt-playground/internal/pkg/validator"
 	"github.com/dyegopenha/jwt-playground/internal/provider/cache/redis"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog/logger"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore/memory"
 )
 
 // Injectors from wire.go:
@@ -22,14 +24,36 @@ import (
 func New() *Server {
 	validation := validator.New()
 	envEnv := env.NewEnv(validation)
-	jwtUtil := jwtutil.NewJWTUtil(envEnv)
-	middlewareMiddleware := middleware.NewMiddleware(jwtUtil)
 	redisRedis := redis.NewRedis(envEnv)
-	signInUseCase := usecase.NewSignInUseCase(envEnv, redisRedis, jwtUtil)
-	refreshUseCase := usecase.NewRefreshUseCase(redisRedis, envEnv, jwtUtil)
-	authHandler := handler.NewAuthHandler(envEnv, signInUseCase, refreshUseCase)
+	jwtUtil := jwtutil.NewJWTUtil(envEnv, redisRedis)
+	verifyAccessTokenUseCase := usecase.NewVerifyAccessTokenUseCase(redisRedis, jwtUtil)
+	verifyDPoPProofUseCase := usecase.NewVerifyDPoPProofUseCase(redisRedis, envEnv, jwtUtil)
+	verifyCapabilityUseCase := usecase.NewVerifyCapabilityUseCase(redisRedis, jwtUtil)
+	middlewareMiddleware := middleware.NewMiddleware(envEnv, verifyAccessTokenUseCase, verifyDPoPProofUseCase, verifyCapabilityUseCase)
+	memoryMemory := memory.NewMemory()
+	signInUseCase := usecase.NewSignInUseCase(envEnv, redisRedis, jwtUtil, memoryMemory)
+	loggerLogger := logger.NewLogger()
+	refreshUseCase := usecase.NewRefreshUseCase(redisRedis, envEnv, jwtUtil, memoryMemory, loggerLogger)
+	authHandler := handler.NewAuthHandler(envEnv, signInUseCase, refreshUseCase, verifyDPoPProofUseCase)
 	userHandler := handler.NewUserHandler()
-	routerRouter := router.NewRouter(middlewareMiddleware, authHandler, userHandler)
-	server := newServer(envEnv, routerRouter)
+	jwksHandler := handler.NewJWKSHandler(jwtUtil)
+	revokeAccessTokenUseCase := usecase.NewRevokeAccessTokenUseCase(redisRedis, envEnv, jwtUtil)
+	revokeUserTokensUseCase := usecase.NewRevokeUserTokensUseCase(redisRedis, envEnv)
+	revokeAllTokensUseCase := usecase.NewRevokeAllTokensUseCase(redisRedis, envEnv)
+	adminHandler := handler.NewAdminHandler(revokeAccessTokenUseCase, revokeUserTokensUseCase, revokeAllTokensUseCase)
+	introspectTokenUseCase := usecase.NewIntrospectTokenUseCase(verifyAccessTokenUseCase)
+	introspectionHandler := handler.NewIntrospectionHandler(introspectTokenUseCase)
+	exchangeTokenUseCase := usecase.NewExchangeTokenUseCase(envEnv, jwtUtil, verifyAccessTokenUseCase, memoryMemory, loggerLogger)
+	tokenHandler := handler.NewTokenHandler(exchangeTokenUseCase, verifyDPoPProofUseCase)
+	issueCapabilityUseCase := usecase.NewIssueCapabilityUseCase(envEnv, jwtUtil)
+	capabilityHandler := handler.NewCapabilityHandler(issueCapabilityUseCase)
+	decodeTokenUseCase := usecase.NewDecodeTokenUseCase(envEnv, jwtUtil)
+	explainTokenUseCase := usecase.NewExplainTokenUseCase(envEnv, redisRedis, jwtUtil)
+	mintTokenUseCase := usecase.NewMintTokenUseCase(envEnv, jwtUtil)
+	debugHandler := handler.NewDebugHandler(decodeTokenUseCase, explainTokenUseCase, mintTokenUseCase)
+	playgroundHandler := handler.NewPlaygroundHandler()
+	routerRouter := router.NewRouter(middlewareMiddleware, authHandler, userHandler, jwksHandler, adminHandler, introspectionHandler, tokenHandler, capabilityHandler, debugHandler, playgroundHandler)
+	keyRotator := jwtutil.NewKeyRotator(envEnv, redisRedis, jwtUtil)
+	server := newServer(envEnv, routerRouter, keyRotator)
 	return server
 }
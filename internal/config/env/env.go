@@ -3,9 +3,13 @@ package env
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	root "github.com/dyegopenha/jwt-playground"
@@ -13,7 +17,19 @@ import (
 	"github.com/spf13/viper"
 )
 
-const defaultEnvFileName = ".env"
+const (
+	defaultEnvFileName = ".env"
+	defaultJWTIssuer   = "jwt-playground"
+
+	defaultJWTClaimsMaxBytes = 1024
+
+	defaultJWTKeyRotationGrace = time.Minute
+	defaultJWTKeySyncInterval  = 30 * time.Second
+
+	defaultDPoPProofMaxAge = time.Minute
+
+	defaultCapabilityTokenTTL = time.Hour
+)
 
 type Environment string
 
@@ -30,9 +46,76 @@ type Env struct {
 	Environment      Environment   `mapstructure:"ENVIRONMENT"        validate:"required,oneof=development production staging test"`
 	Port             string        `mapstructure:"PORT"`
 	RedisDatabaseURL string        `mapstructure:"REDIS_DATABASE_URL" validate:"required"`
-	HMACKey          string        `mapstructure:"HMAC_KEY"           validate:"required"`
+	HMACKey          string        `mapstructure:"HMAC_KEY"`
 	AccessTokenTTL   time.Duration `mapstructure:"ACCESS_TOKEN_TTL"   validate:"required"`
 	RefreshTokenTTL  time.Duration `mapstructure:"REFRESH_TOKEN_TTL"  validate:"required"`
+
+	// TLSCertFile and TLSKeyFile make the server listen over TLS. With
+	// TLSClientCAFile set, client certificates signed by that CA are
+	// verified and tokens are bound to them.
+	TLSCertFile     string `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile      string `mapstructure:"TLS_KEY_FILE"`
+	TLSClientCAFile string `mapstructure:"TLS_CLIENT_CA_FILE"`
+
+	// TokenFormat selects between JWT, PASETO v4 and opaque access tokens.
+	TokenFormat    string `mapstructure:"TOKEN_FORMAT"     validate:"omitempty,oneof=jwt paseto-v4-public paseto-v4-local opaque"`
+	PASETOLocalKey string `mapstructure:"PASETO_LOCAL_KEY"`
+
+	JWTIssuer   string   `mapstructure:"JWT_ISSUER"`
+	JWTAudience []string `mapstructure:"JWT_AUDIENCE"`
+	// JWTLeeway is the clock skew tolerated when checking exp, nbf and iat.
+	JWTLeeway time.Duration `mapstructure:"JWT_LEEWAY" validate:"gte=0"`
+	// JWTMaxTokenAge rejects tokens whose iat is older than this, when set.
+	JWTMaxTokenAge time.Duration `mapstructure:"JWT_MAX_TOKEN_AGE" validate:"gte=0"`
+
+	// JWTClaimsTemplate maps custom claim names to the user attribute they
+	// are filled from.
+	JWTClaimsTemplate map[string]string `mapstructure:"JWT_CLAIMS_TEMPLATE" validate:"dive,keys,required,endkeys,oneof=email tenant_id groups entitlements"`
+	// JWTClaimsMaxBytes caps the serialized size of the custom claims.
+	JWTClaimsMaxBytes int `mapstructure:"JWT_CLAIMS_MAX_BYTES" validate:"gte=0"`
+
+	JWTAlgorithm      string `mapstructure:"JWT_ALGORITHM"        validate:"omitempty,oneof=HS256 HS384 HS512 RS256 RS384 RS512 PS256 PS384 PS512 ES256 ES384 ES512 EdDSA"`
+	JWTPrivateKey     string `mapstructure:"JWT_PRIVATE_KEY"`
+	JWTPrivateKeyFile string `mapstructure:"JWT_PRIVATE_KEY_FILE"`
+	JWTKeyID          string `mapstructure:"JWT_KEY_ID"`
+	// JWTSignerURL delegates signing to an external key service; the
+	// matching public key is read from JWTSignerPublicKeyFile.
+	JWTSignerURL           string `mapstructure:"JWT_SIGNER_URL"`
+	JWTSignerPublicKeyFile string `mapstructure:"JWT_SIGNER_PUBLIC_KEY_FILE"`
+	// JWTVerificationKeys maps the kid of each previous key to the HMAC
+	// secret or, for asymmetric algorithms, the path of its PEM file.
+	JWTVerificationKeys map[string]string `mapstructure:"JWT_VERIFICATION_KEYS"`
+
+	// JWEAlgorithm enables encrypted access tokens when set.
+	JWEAlgorithm      string `mapstructure:"JWE_ALGORITHM"        validate:"omitempty,oneof=dir RSA-OAEP-256"`
+	JWEKey            string `mapstructure:"JWE_KEY"`
+	JWEPrivateKeyFile string `mapstructure:"JWE_PRIVATE_KEY_FILE"`
+
+	// CapabilityKey enables attenuable capability tokens, which are
+	// valid for CapabilityTokenTTL unless restricted further.
+	CapabilityKey      string        `mapstructure:"CAPABILITY_KEY"`
+	CapabilityTokenTTL time.Duration `mapstructure:"CAPABILITY_TOKEN_TTL" validate:"gt=0"`
+
+	// DPoPProofMaxAge is how long after its iat a DPoP proof is accepted.
+	DPoPProofMaxAge time.Duration `mapstructure:"DPOP_PROOF_MAX_AGE" validate:"gt=0"`
+
+	// OAuthClients maps the client ID of each resource server or backend
+	// service allowed to call the introspection and token exchange
+	// endpoints to its secret.
+	OAuthClients map[string]string `mapstructure:"OAUTH_CLIENTS"`
+	// TokenExchangePolicyFile is a JSON file with the token exchange policy
+	// of each client.
+	TokenExchangePolicyFile string `mapstructure:"TOKEN_EXCHANGE_POLICY_FILE"`
+
+	// DebugEndpoints serves the /debug endpoints, which are unauthenticated
+	// and sign tokens with the real keys. It is ignored outside development
+	// and test.
+	DebugEndpoints bool `mapstructure:"DEBUG_ENDPOINTS"`
+
+	// JWTKeyRotationInterval enables automatic key rotation when non-zero.
+	JWTKeyRotationInterval time.Duration `mapstructure:"JWT_KEY_ROTATION_INTERVAL" validate:"gte=0"`
+	JWTKeyRotationGrace    time.Duration `mapstructure:"JWT_KEY_ROTATION_GRACE"    validate:"gte=0"`
+	JWTKeySyncInterval     time.Duration `mapstructure:"JWT_KEY_SYNC_INTERVAL"     validate:"gt=0"`
 }
 
 func NewEnv(v validator.Validator) *Env {
@@ -48,12 +131,42 @@ func NewEnv(v validator.Validator) *Env {
 }
 
 type envVariables struct {
-	Environment        Environment `mapstructure:"ENVIRONMENT"        validate:"required,oneof=development production staging test"`
-	Port               string      `mapstructure:"PORT"`
-	RedisDatabaseURL   string      `mapstructure:"REDIS_DATABASE_URL" validate:"required"`
-	HMACKey            string      `mapstructure:"HMAC_KEY"           validate:"required"`
-	AccessTokenTTLStr  string      `mapstructure:"ACCESS_TOKEN_TTL"   validate:"required"`
-	RefreshTokenTTLStr string      `mapstructure:"REFRESH_TOKEN_TTL"  validate:"required"`
+	Environment               Environment `mapstructure:"ENVIRONMENT"        validate:"required,oneof=development production staging test"`
+	Port                      string      `mapstructure:"PORT"`
+	RedisDatabaseURL          string      `mapstructure:"REDIS_DATABASE_URL" validate:"required"`
+	HMACKey                   string      `mapstructure:"HMAC_KEY"`
+	AccessTokenTTLStr         string      `mapstructure:"ACCESS_TOKEN_TTL"   validate:"required"`
+	RefreshTokenTTLStr        string      `mapstructure:"REFRESH_TOKEN_TTL"  validate:"required"`
+	TLSCertFile               string      `mapstructure:"TLS_CERT_FILE"`
+	TLSKeyFile                string      `mapstructure:"TLS_KEY_FILE"`
+	TLSClientCAFile           string      `mapstructure:"TLS_CLIENT_CA_FILE"`
+	TokenFormat               string      `mapstructure:"TOKEN_FORMAT"`
+	PASETOLocalKey            string      `mapstructure:"PASETO_LOCAL_KEY"`
+	JWTIssuer                 string      `mapstructure:"JWT_ISSUER"`
+	JWTAudienceStr            string      `mapstructure:"JWT_AUDIENCE"`
+	JWTLeewayStr              string      `mapstructure:"JWT_LEEWAY"`
+	JWTMaxTokenAgeStr         string      `mapstructure:"JWT_MAX_TOKEN_AGE"`
+	JWTClaimsTemplateStr      string      `mapstructure:"JWT_CLAIMS_TEMPLATE"`
+	JWTClaimsMaxBytesStr      string      `mapstructure:"JWT_CLAIMS_MAX_BYTES"`
+	JWTAlgorithm              string      `mapstructure:"JWT_ALGORITHM"`
+	JWTPrivateKey             string      `mapstructure:"JWT_PRIVATE_KEY"`
+	JWTPrivateKeyFile         string      `mapstructure:"JWT_PRIVATE_KEY_FILE"`
+	JWTKeyID                  string      `mapstructure:"JWT_KEY_ID"`
+	JWTSignerURL              string      `mapstructure:"JWT_SIGNER_URL"`
+	JWTSignerPublicKeyFile    string      `mapstructure:"JWT_SIGNER_PUBLIC_KEY_FILE"`
+	JWTVerificationKeysStr    string      `mapstructure:"JWT_VERIFICATION_KEYS"`
+	JWTKeyRotationIntervalStr string      `mapstructure:"JWT_KEY_ROTATION_INTERVAL"`
+	JWTKeyRotationGraceStr    string      `mapstructure:"JWT_KEY_ROTATION_GRACE"`
+	JWTKeySyncIntervalStr     string      `mapstructure:"JWT_KEY_SYNC_INTERVAL"`
+	JWEAlgorithm              string      `mapstructure:"JWE_ALGORITHM"`
+	JWEKey                    string      `mapstructure:"JWE_KEY"`
+	JWEPrivateKeyFile         string      `mapstructure:"JWE_PRIVATE_KEY_FILE"`
+	CapabilityKey             string      `mapstructure:"CAPABILITY_KEY"`
+	CapabilityTokenTTLStr     string      `mapstructure:"CAPABILITY_TOKEN_TTL"`
+	DPoPProofMaxAgeStr        string      `mapstructure:"DPOP_PROOF_MAX_AGE"`
+	OAuthClientsStr           string      `mapstructure:"OAUTH_CLIENTS"`
+	DebugEndpointsStr         string      `mapstructure:"DEBUG_ENDPOINTS"`
+	TokenExchangePolicyFile   string      `mapstructure:"TOKEN_EXCHANGE_POLICY_FILE"`
 }
 
 func (e *Env) loadEnv() error {
@@ -91,6 +204,59 @@ func (e *Env) parseEnvVariables(envVariables envVariables) error {
 	e.Port = envVariables.Port
 	e.RedisDatabaseURL = envVariables.RedisDatabaseURL
 	e.HMACKey = envVariables.HMACKey
+	e.TLSCertFile = envVariables.TLSCertFile
+	e.TLSKeyFile = envVariables.TLSKeyFile
+	e.TLSClientCAFile = envVariables.TLSClientCAFile
+	e.TokenFormat = envVariables.TokenFormat
+	e.PASETOLocalKey = envVariables.PASETOLocalKey
+	e.JWTIssuer = envVariables.JWTIssuer
+	e.JWTAudience = parseList(envVariables.JWTAudienceStr)
+	e.JWTAlgorithm = envVariables.JWTAlgorithm
+
+	claimsTemplate, err := parseKeyValueList(envVariables.JWTClaimsTemplateStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse jwt claims template: %w", err)
+	}
+	e.JWTClaimsTemplate = claimsTemplate
+
+	e.JWTClaimsMaxBytes = defaultJWTClaimsMaxBytes
+	if envVariables.JWTClaimsMaxBytesStr != "" {
+		if e.JWTClaimsMaxBytes, err = strconv.Atoi(
+			envVariables.JWTClaimsMaxBytesStr,
+		); err != nil {
+			return fmt.Errorf("failed to parse jwt claims max bytes: %w", err)
+		}
+	}
+	// Allow PEM keys to be written on a single line with escaped newlines.
+	e.JWTPrivateKey = strings.ReplaceAll(envVariables.JWTPrivateKey, `\n`, "\n")
+	e.JWTPrivateKeyFile = envVariables.JWTPrivateKeyFile
+	e.JWTKeyID = envVariables.JWTKeyID
+	e.JWTSignerURL = envVariables.JWTSignerURL
+	e.JWTSignerPublicKeyFile = envVariables.JWTSignerPublicKeyFile
+	e.JWEAlgorithm = envVariables.JWEAlgorithm
+	e.JWEKey = envVariables.JWEKey
+	e.JWEPrivateKeyFile = envVariables.JWEPrivateKeyFile
+
+	verificationKeys, err := parseKeyValueList(envVariables.JWTVerificationKeysStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse jwt verification keys: %w", err)
+	}
+	e.JWTVerificationKeys = verificationKeys
+
+	oauthClients, err := parseKeyValueList(envVariables.OAuthClientsStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse oauth clients: %w", err)
+	}
+	e.OAuthClients = oauthClients
+	e.TokenExchangePolicyFile = envVariables.TokenExchangePolicyFile
+
+	if envVariables.DebugEndpointsStr != "" {
+		if e.DebugEndpoints, err = strconv.ParseBool(
+			envVariables.DebugEndpointsStr,
+		); err != nil {
+			return fmt.Errorf("failed to parse debug endpoints: %w", err)
+		}
+	}
 
 	accessTokenTTL, err := time.ParseDuration(envVariables.AccessTokenTTLStr)
 	if err != nil {
@@ -104,9 +270,92 @@ func (e *Env) parseEnvVariables(envVariables envVariables) error {
 	}
 	e.RefreshTokenTTL = refreshTokenTTL
 
+	if e.JWTLeeway, err = parseOptionalDuration(
+		envVariables.JWTLeewayStr,
+		0,
+	); err != nil {
+		return fmt.Errorf("failed to parse jwt leeway: %w", err)
+	}
+	if e.JWTMaxTokenAge, err = parseOptionalDuration(
+		envVariables.JWTMaxTokenAgeStr,
+		0,
+	); err != nil {
+		return fmt.Errorf("failed to parse jwt max token age: %w", err)
+	}
+	if e.JWTKeyRotationInterval, err = parseOptionalDuration(
+		envVariables.JWTKeyRotationIntervalStr,
+		0,
+	); err != nil {
+		return fmt.Errorf("failed to parse jwt key rotation interval: %w", err)
+	}
+	if e.JWTKeyRotationGrace, err = parseOptionalDuration(
+		envVariables.JWTKeyRotationGraceStr,
+		defaultJWTKeyRotationGrace,
+	); err != nil {
+		return fmt.Errorf("failed to parse jwt key rotation grace: %w", err)
+	}
+	if e.JWTKeySyncInterval, err = parseOptionalDuration(
+		envVariables.JWTKeySyncIntervalStr,
+		defaultJWTKeySyncInterval,
+	); err != nil {
+		return fmt.Errorf("failed to parse jwt key sync interval: %w", err)
+	}
+	e.CapabilityKey = envVariables.CapabilityKey
+	if e.CapabilityTokenTTL, err = parseOptionalDuration(
+		envVariables.CapabilityTokenTTLStr,
+		defaultCapabilityTokenTTL,
+	); err != nil {
+		return fmt.Errorf("failed to parse capability token ttl: %w", err)
+	}
+	if e.DPoPProofMaxAge, err = parseOptionalDuration(
+		envVariables.DPoPProofMaxAgeStr,
+		defaultDPoPProofMaxAge,
+	); err != nil {
+		return fmt.Errorf("failed to parse dpop proof max age: %w", err)
+	}
+
 	return nil
 }
 
+// parseOptionalDuration parses a duration, returning def when s is empty.
+func parseOptionalDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseList parses a comma-separated list, dropping empty entries.
+func parseList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseKeyValueList parses a comma-separated list of key=value pairs.
+func parseKeyValueList(s string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected key=value", pair)
+		}
+		if _, dup := m[k]; dup {
+			return nil, fmt.Errorf("duplicate key %q", k)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
 func (e *Env) getEnvFile() (envFile []byte, err error) {
 	environment := os.Getenv("ENVIRONMENT")
 
@@ -136,5 +385,137 @@ func (e *Env) validate() error {
 	if e.Port == "" {
 		e.Port = "8080"
 	}
+	if e.JWTAlgorithm == "" {
+		e.JWTAlgorithm = "HS256"
+	}
+	if e.TokenFormat == "" {
+		e.TokenFormat = "jwt"
+	}
+	if e.JWTIssuer == "" {
+		e.JWTIssuer = defaultJWTIssuer
+	}
+	if len(e.JWTAudience) == 0 {
+		e.JWTAudience = []string{defaultJWTIssuer}
+	}
+
+	switch {
+	case e.JWTSignerURL != "":
+		if e.IsHMAC() {
+			return errors.New("JWT_SIGNER_URL requires an asymmetric signing algorithm")
+		}
+		if e.JWTSignerPublicKeyFile == "" {
+			return errors.New("JWT_SIGNER_PUBLIC_KEY_FILE is required with JWT_SIGNER_URL")
+		}
+		if e.JWTKeyRotationInterval > 0 {
+			return errors.New("JWT_KEY_ROTATION_INTERVAL cannot be used with JWT_SIGNER_URL")
+		}
+	case e.IsHMAC():
+		if e.HMACKey == "" {
+			return errors.New("HMAC_KEY is required for HMAC signing algorithms")
+		}
+	case e.JWTPrivateKey == "" && e.JWTPrivateKeyFile == "":
+		return errors.New(
+			"JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE is required for asymmetric signing algorithms",
+		)
+	}
+	if (e.TLSCertFile == "") != (e.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if e.TLSClientCAFile != "" && e.TLSCertFile == "" {
+		return errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	switch e.TokenFormat {
+	case "paseto-v4-public":
+		if e.JWTAlgorithm != "EdDSA" {
+			return errors.New("TOKEN_FORMAT paseto-v4-public requires JWT_ALGORITHM EdDSA")
+		}
+	case "paseto-v4-local":
+		if e.PASETOLocalKey == "" {
+			return errors.New("PASETO_LOCAL_KEY is required for paseto-v4-local")
+		}
+	}
+	if e.TokenFormat != "jwt" && e.JWEAlgorithm != "" {
+		return errors.New("JWE_ALGORITHM can only be used with TOKEN_FORMAT jwt")
+	}
+
+	switch e.JWEAlgorithm {
+	case "dir":
+		if e.JWEKey == "" {
+			return errors.New("JWE_KEY is required for dir encryption")
+		}
+	case "RSA-OAEP-256":
+		if e.JWEPrivateKeyFile == "" {
+			return errors.New("JWE_PRIVATE_KEY_FILE is required for RSA-OAEP-256 encryption")
+		}
+	}
+	if e.JWTKeyRotationInterval > 0 &&
+		e.JWTKeyRotationInterval <= 2*e.JWTKeySyncInterval {
+		return errors.New(
+			"JWT_KEY_ROTATION_INTERVAL must be longer than twice JWT_KEY_SYNC_INTERVAL",
+		)
+	}
 	return nil
 }
+
+// IsHMAC reports whether the configured JWT algorithm is a shared-secret
+// (HS*) algorithm.
+func (e *Env) IsHMAC() bool {
+	return strings.HasPrefix(e.JWTAlgorithm, "HS")
+}
+
+// IsDevelopment reports whether the server runs in development or test.
+// Debugging tools only work then, and no setting can enable them in staging
+// or production.
+func (e *Env) IsDevelopment() bool {
+	return e.Environment == EnvironmentDevelopment ||
+		e.Environment == EnvironmentTest
+}
+
+// DebugEndpointsEnabled reports whether the /debug endpoints are served:
+// they must be turned on with DEBUG_ENDPOINTS, in development or test.
+func (e *Env) DebugEndpointsEnabled() bool {
+	return e.IsDevelopment() && e.DebugEndpoints
+}
+
+// redacted replaces secret values in Redacted.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the environment with every secret replaced,
+// safe to print. Key files are referenced by path and are kept as they are.
+func (e *Env) Redacted() *Env {
+	r := *e
+	r.HMACKey = redact(e.HMACKey)
+	r.PASETOLocalKey = redact(e.PASETOLocalKey)
+	r.JWTPrivateKey = redact(e.JWTPrivateKey)
+	r.JWEKey = redact(e.JWEKey)
+	r.CapabilityKey = redact(e.CapabilityKey)
+	r.OAuthClients = redactValues(e.OAuthClients)
+	// Previous keys are HMAC secrets for HS* algorithms and PEM file paths
+	// otherwise.
+	r.JWTVerificationKeys = e.JWTVerificationKeys
+	if e.IsHMAC() {
+		r.JWTVerificationKeys = redactValues(e.JWTVerificationKeys)
+	}
+	if u, err := url.Parse(e.RedisDatabaseURL); err == nil {
+		r.RedisDatabaseURL = u.Redacted()
+	} else {
+		r.RedisDatabaseURL = redact(e.RedisDatabaseURL)
+	}
+	return &r
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactValues(m map[string]string) map[string]string {
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = redact(v)
+	}
+	return r
+}
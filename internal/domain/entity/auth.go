@@ -2,8 +2,33 @@ package entity
 
 import "time"
 
+// RefreshSession is the record stored for each signed-in session, under its
+// current refresh token. Rotating the refresh token moves the record to the
+// new token; ID, CreatedAt and ExpiresAt stay the same for the life of the
+// session.
 type RefreshSession struct {
+	// ID identifies the session across refresh token rotations.
+	ID        string
 	UserID    string
 	Role      string
-	ExpiresAt time.Time
+	CreatedAt time.Time
+	// ExpiresAt is when the session ends, however often it is refreshed.
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	// IP and UserAgent describe the client that last signed in or
+	// refreshed with the session.
+	IP        string
+	UserAgent string
+	// JKT and X5TS256 are the thumbprints of the DPoP key and the TLS
+	// client certificate the session is bound to, if any. Refreshing a
+	// bound session requires the same key or certificate.
+	JKT     string
+	X5TS256 string
+}
+
+// ClientInfo describes the client making a sign-in, refresh or token
+// exchange request.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
 }
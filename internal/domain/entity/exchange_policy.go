@@ -0,0 +1,41 @@
+package entity
+
+import "slices"
+
+// ExchangePolicy limits the tokens a client may obtain through token
+// exchange.
+type ExchangePolicy struct {
+	ClientID string `json:"client_id"`
+	// Audiences the client may request tokens for.
+	Audiences []string `json:"audiences"`
+	// Scopes the client may request.
+	Scopes []string `json:"scopes"`
+	// ImpersonatorRoles are the roles of the actors the client may
+	// impersonate users on behalf of. Impersonation is disabled when empty.
+	ImpersonatorRoles []string `json:"impersonator_roles"`
+	// ImpersonableRoles are the roles of the users who may be impersonated.
+	// Users with any other role, such as administrators, never are.
+	ImpersonableRoles []string `json:"impersonable_roles"`
+}
+
+// AllowsAudience reports whether every audience may be requested.
+func (p *ExchangePolicy) AllowsAudience(audiences []string) bool {
+	for _, aud := range audiences {
+		if !slices.Contains(p.Audiences, aud) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsImpersonationBy reports whether an actor with the given role may
+// impersonate users through this client.
+func (p *ExchangePolicy) AllowsImpersonationBy(role string) bool {
+	return role != "" && slices.Contains(p.ImpersonatorRoles, role)
+}
+
+// AllowsImpersonationOf reports whether a user with the given role may be
+// impersonated through this client.
+func (p *ExchangePolicy) AllowsImpersonationOf(role string) bool {
+	return role != "" && slices.Contains(p.ImpersonableRoles, role)
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+// SecurityEventType names a kind of security event.
+type SecurityEventType string
+
+const (
+	// SecurityEventRefreshTokenReuse is recorded when a refresh token that
+	// was already rotated is presented again, and its session is revoked.
+	SecurityEventRefreshTokenReuse SecurityEventType = "refresh_token_reuse"
+	// SecurityEventDelegation is recorded when a client exchanges a user's
+	// access token for one acting on the user's behalf.
+	SecurityEventDelegation SecurityEventType = "token_exchange_delegation"
+	// SecurityEventImpersonation is recorded when a staff member obtains a
+	// token impersonating a user.
+	SecurityEventImpersonation SecurityEventType = "token_exchange_impersonation"
+)
+
+// SecurityEvent describes something security teams may want to alert on,
+// such as a stolen refresh token being used.
+type SecurityEvent struct {
+	Type      SecurityEventType `json:"type"`
+	Time      time.Time         `json:"time"`
+	UserID    string            `json:"user_id,omitempty"`
+	SessionID string            `json:"session_id,omitempty"`
+	// IP and UserAgent describe the client that caused the event.
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// ClientID, ActorID, Audience and Scope describe a token exchange: the
+	// client that made it, the actor the token was issued to and what the
+	// token grants.
+	ClientID string   `json:"client_id,omitempty"`
+	ActorID  string   `json:"actor_id,omitempty"`
+	Audience []string `json:"audience,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+type User struct {
+	ID           string
+	Email        string
+	Role         string
+	TenantID     string
+	Groups       []string
+	Entitlements []string
+}
+
+// ClaimAttributes returns the user-record values that access-token claims
+// templates may reference, keyed by attribute name.
+func (u *User) ClaimAttributes() map[string]any {
+	return map[string]any{
+		"email":        u.Email,
+		"tenant_id":    u.TenantID,
+		"groups":       u.Groups,
+		"entitlements": u.Entitlements,
+	}
+}
@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+// Errors returned when a sender-constrained token is presented by a client
+// that cannot prove possession of the key it is bound to.
+var (
+	ErrDPoPKeyMismatch     = errors.New("dpop key does not match token binding")
+	ErrCertificateMismatch = errors.New("client certificate does not match token binding")
+)
+
+// checkBinding rejects a sender-constrained token unless cnf, the keys the
+// client proved possession of, includes the key it is bound to.
+func checkBinding(claims *jwtutil.Claims, cnf jwtutil.Confirmation) error {
+	if claims.Confirmation == nil {
+		return nil
+	}
+	if jkt := claims.Confirmation.JKT; jkt != "" && jkt != cnf.JKT {
+		return ErrDPoPKeyMismatch
+	}
+	if x5t := claims.Confirmation.X5TS256; x5t != "" && x5t != cnf.X5TS256 {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
+
+// bindingOf returns the key binding of claims, which is empty for bearer
+// tokens.
+func bindingOf(claims *jwtutil.Claims) jwtutil.Confirmation {
+	if claims.Confirmation == nil {
+		return jwtutil.Confirmation{}
+	}
+	return *claims.Confirmation
+}
@@ -0,0 +1,7 @@
+package usecase
+
+import "errors"
+
+// ErrDebugDisabled is returned by the debugging usecases outside of
+// development and test.
+var ErrDebugDisabled = errors.New("debugging is disabled in this environment")
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+type DecodeTokenUseCase struct {
+	e *env.Env
+	j *jwtutil.JWTUtil
+}
+
+func NewDecodeTokenUseCase(
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+) *DecodeTokenUseCase {
+	return &DecodeTokenUseCase{
+		e: e,
+		j: j,
+	}
+}
+
+// Execute decodes a token's header and claims without verifying them.
+func (u *DecodeTokenUseCase) Execute(
+	ctx context.Context,
+	token string,
+) (*jwtutil.DecodedToken, error) {
+	if !u.e.IsDevelopment() {
+		return nil, ErrDebugDisabled
+	}
+	return u.j.Decode(ctx, token)
+}
@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
+)
+
+// Token types accepted by the token exchange. TokenTypeUserID is specific
+// to this server and names the user to impersonate by ID.
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeUserID      = "urn:jwt-playground:params:oauth:token-type:user_id"
+)
+
+// maxActorDepth bounds the act chain of exchanged tokens.
+const maxActorDepth = 5
+
+// Token exchange errors. Their messages are the RFC 6749 and RFC 8693 error
+// codes they map to.
+var (
+	ErrInvalidRequest     = errors.New("invalid_request")
+	ErrInvalidGrant       = errors.New("invalid_grant")
+	ErrInvalidTarget      = errors.New("invalid_target")
+	ErrInvalidScope       = errors.New("invalid_scope")
+	ErrUnauthorizedClient = errors.New("unauthorized_client")
+)
+
+// TokenExchangeRequest is an RFC 8693 token exchange request made by an
+// authenticated client.
+type TokenExchangeRequest struct {
+	ClientID string
+	Client   entity.ClientInfo
+	// Confirmation holds the keys the client proved possession of with the
+	// request: the key of its DPoP proof and its TLS client certificate.
+	Confirmation     jwtutil.Confirmation
+	SubjectToken     string
+	SubjectTokenType string
+	ActorToken       string
+	ActorTokenType   string
+	Audience         []string
+	Scope            []string
+}
+
+type TokenExchangeResult struct {
+	AccessToken string
+	ExpiresIn   time.Duration
+	Scope       string
+}
+
+type ExchangeTokenUseCase struct {
+	e        *env.Env
+	j        *jwtutil.JWTUtil
+	vac      *VerifyAccessTokenUseCase
+	us       userstore.UserStore
+	sl       securitylog.SecurityLog
+	policies map[string]entity.ExchangePolicy
+}
+
+func NewExchangeTokenUseCase(
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+	vac *VerifyAccessTokenUseCase,
+	us userstore.UserStore,
+	sl securitylog.SecurityLog,
+) *ExchangeTokenUseCase {
+	policies, err := loadExchangePolicies(e.TokenExchangePolicyFile)
+	if err != nil {
+		log.Fatalf("failed to load token exchange policies: %v", err)
+	}
+
+	return &ExchangeTokenUseCase{
+		e:        e,
+		j:        j,
+		vac:      vac,
+		us:       us,
+		sl:       sl,
+		policies: policies,
+	}
+}
+
+// loadExchangePolicies reads the JSON array of policies in path. Without a
+// policy file no client may exchange tokens.
+func loadExchangePolicies(path string) (map[string]entity.ExchangePolicy, error) {
+	policies := map[string]entity.ExchangePolicy{}
+	if path == "" {
+		return policies, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []entity.ExchangePolicy
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		if p.ClientID == "" {
+			return nil, errors.New("policy without client_id")
+		}
+		if _, dup := policies[p.ClientID]; dup {
+			return nil, fmt.Errorf("duplicate policy for client %q", p.ClientID)
+		}
+		policies[p.ClientID] = p
+	}
+	return policies, nil
+}
+
+// Execute exchanges a subject token for an access token acting on behalf of
+// the subject, as allowed by the client's policy.
+//
+// For delegation the subject token is the user's access token, and the new
+// token's act claim names the actor token's subject, or the client when no
+// actor token is given. For impersonation the subject token is a user ID of
+// type TokenTypeUserID and an actor token is required, whose role the
+// policy must allow to impersonate, as it must allow the role of the
+// impersonated user. Exchanged tokens are restricted to the
+// requested audiences and scopes and never outlive the tokens they were
+// obtained with. Impersonation requires an actor token that is not
+// restricted itself. Sender-constrained subject and actor tokens are only
+// accepted from a client proving possession of their key, and the exchanged
+// token is bound to the same key. The exchanged token is revoked with the
+// refresh session of the subject token, or of the actor token for
+// impersonation. Every exchange is recorded as a security event.
+func (u *ExchangeTokenUseCase) Execute(
+	ctx context.Context,
+	req TokenExchangeRequest,
+) (*TokenExchangeResult, error) {
+	policy, ok := u.policies[req.ClientID]
+	if !ok {
+		return nil, fmt.Errorf("%w: client may not exchange tokens", ErrUnauthorizedClient)
+	}
+
+	var actor *jwtutil.Claims
+	if req.ActorToken != "" {
+		if req.ActorTokenType != TokenTypeAccessToken {
+			return nil, fmt.Errorf("%w: unsupported actor_token_type", ErrInvalidRequest)
+		}
+		claims, err := u.verify(ctx, req.ActorToken, req.Confirmation)
+		if err != nil {
+			return nil, err
+		}
+		actor = claims
+	}
+
+	var (
+		kind      entity.SecurityEventType
+		userID    string
+		chain     *jwtutil.Actor
+		baseScope []string
+		expiresAt time.Time
+		binding   jwtutil.Confirmation
+		sessionID string
+	)
+	switch req.SubjectTokenType {
+	case TokenTypeAccessToken:
+		subject, err := u.verify(ctx, req.SubjectToken, req.Confirmation)
+		if err != nil {
+			return nil, err
+		}
+		kind = entity.SecurityEventDelegation
+		userID = subject.Subject
+		binding = bindingOf(subject)
+		sessionID = subject.SessionID
+		expiresAt = subject.ExpiresAt.Time
+		if subject.Scope != "" {
+			baseScope = strings.Fields(subject.Scope)
+		}
+		if actor != nil {
+			chain = &jwtutil.Actor{Subject: actor.Subject, Actor: subject.Actor}
+			expiresAt = earliest(expiresAt, actor.ExpiresAt.Time)
+		} else {
+			chain = &jwtutil.Actor{Subject: req.ClientID, Actor: subject.Actor}
+		}
+
+	case TokenTypeUserID:
+		if actor == nil {
+			return nil, fmt.Errorf("%w: impersonation requires an actor_token", ErrInvalidRequest)
+		}
+		if actor.Capability || actor.Actor != nil || actor.Scope != "" {
+			return nil, fmt.Errorf(
+				"%w: impersonation requires an unrestricted actor_token",
+				ErrInvalidGrant,
+			)
+		}
+		if !policy.AllowsImpersonationBy(actor.Role) {
+			return nil, fmt.Errorf("%w: actor may not impersonate users", ErrUnauthorizedClient)
+		}
+		kind = entity.SecurityEventImpersonation
+		userID = req.SubjectToken
+		chain = &jwtutil.Actor{Subject: actor.Subject}
+		sessionID = actor.SessionID
+		expiresAt = actor.ExpiresAt.Time
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported subject_token_type", ErrInvalidRequest)
+	}
+
+	if actor != nil {
+		// Both tokens were checked against the same proof, so any key they
+		// are bound to is the client's.
+		actorBinding := bindingOf(actor)
+		if actorBinding.JKT != "" {
+			binding.JKT = actorBinding.JKT
+		}
+		if actorBinding.X5TS256 != "" {
+			binding.X5TS256 = actorBinding.X5TS256
+		}
+	}
+
+	if chain.Depth() > maxActorDepth {
+		return nil, fmt.Errorf("%w: delegation chain too long", ErrInvalidRequest)
+	}
+
+	audience := req.Audience
+	if len(audience) == 0 {
+		audience = policy.Audiences
+	}
+	if len(audience) == 0 || !policy.AllowsAudience(audience) {
+		return nil, fmt.Errorf("%w: audience not allowed", ErrInvalidTarget)
+	}
+
+	scope, err := grantedScope(policy.Scopes, baseScope, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := min(u.e.AccessTokenTTL, time.Until(expiresAt))
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidGrant)
+	}
+
+	user, err := u.us.FindByID(ctx, userID)
+	if errors.Is(err, userstore.ErrUserNotFound) {
+		return nil, fmt.Errorf("%w: unknown subject", ErrInvalidGrant)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if kind == entity.SecurityEventImpersonation && !policy.AllowsImpersonationOf(user.Role) {
+		return nil, fmt.Errorf("%w: subject may not be impersonated", ErrInvalidGrant)
+	}
+
+	sub := tokenSubject(user, binding)
+	sub.Audience = audience
+	sub.Scope = scope
+	sub.Actor = chain
+	sub.SessionID = sessionID
+	accessToken, err := u.j.SignAccessToken(ctx, sub, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	u.sl.Record(ctx, entity.SecurityEvent{
+		Type:      kind,
+		Time:      time.Now(),
+		UserID:    user.ID,
+		SessionID: sessionID,
+		IP:        req.Client.IP,
+		UserAgent: req.Client.UserAgent,
+		ClientID:  req.ClientID,
+		ActorID:   chain.Subject,
+		Audience:  audience,
+		Scope:     scope,
+	})
+
+	return &TokenExchangeResult{
+		AccessToken: accessToken,
+		ExpiresIn:   ttl,
+		Scope:       scope,
+	}, nil
+}
+
+// verify checks a subject or actor token and its key binding against cnf,
+// reporting rejected tokens as ErrInvalidGrant.
+func (u *ExchangeTokenUseCase) verify(
+	ctx context.Context,
+	token string,
+	cnf jwtutil.Confirmation,
+) (*jwtutil.Claims, error) {
+	claims, err := u.vac.Execute(ctx, token)
+	if err != nil {
+		var verr *jwtutil.VerificationError
+		if errors.As(err, &verr) || errors.Is(err, ErrTokenRevoked) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidGrant, err)
+		}
+		return nil, err
+	}
+	if err := checkBinding(claims, cnf); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGrant, err)
+	}
+	return claims, nil
+}
+
+// grantedScope narrows the scopes the policy allows down to those the
+// subject token already had, if it was scoped, and then to the requested
+// ones. The grant may not be empty, since an unscoped token is unrestricted.
+func grantedScope(allowed, subject, requested []string) (string, error) {
+	if subject != nil {
+		allowed = slices.DeleteFunc(slices.Clone(allowed), func(s string) bool {
+			return !slices.Contains(subject, s)
+		})
+	}
+
+	granted := allowed
+	if len(requested) > 0 {
+		for _, s := range requested {
+			if !slices.Contains(allowed, s) {
+				return "", fmt.Errorf("%w: scope %q not allowed", ErrInvalidScope, s)
+			}
+		}
+		granted = requested
+	}
+	if len(granted) == 0 {
+		return "", fmt.Errorf("%w: no scope can be granted", ErrInvalidScope)
+	}
+	return strings.Join(granted, " "), nil
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
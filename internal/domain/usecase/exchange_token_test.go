@@ -0,0 +1,394 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	memorycache "github.com/dyegopenha/jwt-playground/internal/provider/cache/memory"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
+)
+
+// users is a userstore.UserStore holding an administrator, a support agent
+// and a regular user.
+type users map[string]*entity.User
+
+var exchangeTestUsers = users{
+	"1": {ID: "1", Email: "admin@example.com", Role: "admin"},
+	"2": {ID: "2", Email: "support@example.com", Role: "support"},
+	"3": {ID: "3", Email: "user@example.com", Role: "user"},
+}
+
+func (u users) FindByID(_ context.Context, id string) (*entity.User, error) {
+	if user, ok := u[id]; ok {
+		return user, nil
+	}
+	return nil, userstore.ErrUserNotFound
+}
+
+func (u users) FindByEmail(_ context.Context, email string) (*entity.User, error) {
+	for _, user := range u {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, userstore.ErrUserNotFound
+}
+
+var exchangeTestPolicies = []entity.ExchangePolicy{
+	{
+		ClientID:  "orders",
+		Audiences: []string{"payments", "shipping"},
+		Scopes:    []string{"payments:read", "payments:write"},
+	},
+	{
+		ClientID:          "support-console",
+		Audiences:         []string{"jwt-playground"},
+		Scopes:            []string{"profile:read"},
+		ImpersonatorRoles: []string{"support"},
+		ImpersonableRoles: []string{"user"},
+	},
+}
+
+type exchangeTest struct {
+	j        *jwtutil.JWTUtil
+	exchange *ExchangeTokenUseCase
+	events   *securityEvents
+}
+
+func newExchangeTest(t *testing.T) *exchangeTest {
+	t.Helper()
+
+	b, err := json.Marshal(exchangeTestPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyFile, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server accepts every audience of the policies, so that exchanged
+	// tokens can be verified and exchanged again.
+	e := &env.Env{
+		Environment:             env.EnvironmentTest,
+		JWTAlgorithm:            "HS256",
+		HMACKey:                 "test-hmac-secret-that-is-long-enough",
+		JWTIssuer:               "jwt-playground",
+		JWTAudience:             []string{"jwt-playground", "payments", "shipping"},
+		JWTClaimsMaxBytes:       1024,
+		AccessTokenTTL:          time.Minute,
+		TokenFormat:             jwtutil.TokenFormatJWT,
+		TokenExchangePolicyFile: policyFile,
+	}
+	c := memorycache.NewMemory()
+	j := jwtutil.NewJWTUtil(e, c)
+	events := &securityEvents{}
+	return &exchangeTest{
+		j:        j,
+		exchange: NewExchangeTokenUseCase(e, j, NewVerifyAccessTokenUseCase(c, j), exchangeTestUsers, events),
+		events:   events,
+	}
+}
+
+// token signs an access token for the user, restricted to scope if set.
+func (x *exchangeTest) token(t *testing.T, userID, scope string) string {
+	t.Helper()
+
+	sub := tokenSubject(exchangeTestUsers[userID], jwtutil.Confirmation{})
+	sub.Scope = scope
+	token, err := x.j.SignAccessToken(context.Background(), sub, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestExchangeToken(t *testing.T) {
+	x := newExchangeTest(t)
+
+	user := x.token(t, "3", "")
+	scopedUser := x.token(t, "3", "payments:read")
+	support := x.token(t, "2", "")
+	scopedSupport := x.token(t, "2", "profile:read")
+	admin := x.token(t, "1", "")
+
+	// delegated is a token the orders client obtained for the user.
+	delegated, err := x.exchange.Execute(context.Background(), TokenExchangeRequest{
+		ClientID:         "orders",
+		SubjectToken:     user,
+		SubjectTokenType: TokenTypeAccessToken,
+		Audience:         []string{"payments"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  TokenExchangeRequest
+		err  error
+		// event is the type of the recorded security event, and sub, act,
+		// aud and scope describe the issued token, act listing the actor
+		// chain from the outermost actor.
+		event entity.SecurityEventType
+		sub   string
+		act   []string
+		aud   []string
+		scope string
+	}{
+		{
+			name: "delegation to the client",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user,
+				SubjectTokenType: TokenTypeAccessToken,
+			},
+			event: entity.SecurityEventDelegation,
+			sub:   "3",
+			act:   []string{"orders"},
+			aud:   []string{"payments", "shipping"},
+			scope: "payments:read payments:write",
+		},
+		{
+			name: "delegation to an actor",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user,
+				SubjectTokenType: TokenTypeAccessToken,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+				Audience:         []string{"payments"},
+				Scope:            []string{"payments:read"},
+			},
+			event: entity.SecurityEventDelegation,
+			sub:   "3",
+			act:   []string{"2"},
+			aud:   []string{"payments"},
+			scope: "payments:read",
+		},
+		{
+			name: "delegation of a delegated token",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     delegated.AccessToken,
+				SubjectTokenType: TokenTypeAccessToken,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+				Audience:         []string{"shipping"},
+			},
+			event: entity.SecurityEventDelegation,
+			sub:   "3",
+			act:   []string{"2", "orders"},
+			aud:   []string{"shipping"},
+			scope: "payments:read payments:write",
+		},
+		{
+			name: "scoped subject narrows the grant",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     scopedUser,
+				SubjectTokenType: TokenTypeAccessToken,
+			},
+			event: entity.SecurityEventDelegation,
+			sub:   "3",
+			act:   []string{"orders"},
+			aud:   []string{"payments", "shipping"},
+			scope: "payments:read",
+		},
+		{
+			name: "scope beyond the scoped subject",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     scopedUser,
+				SubjectTokenType: TokenTypeAccessToken,
+				Scope:            []string{"payments:write"},
+			},
+			err: ErrInvalidScope,
+		},
+		{
+			name: "scope beyond the policy",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user,
+				SubjectTokenType: TokenTypeAccessToken,
+				Scope:            []string{"admin"},
+			},
+			err: ErrInvalidScope,
+		},
+		{
+			name: "audience beyond the policy",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user,
+				SubjectTokenType: TokenTypeAccessToken,
+				Audience:         []string{"jwt-playground"},
+			},
+			err: ErrInvalidTarget,
+		},
+		{
+			name: "client without a policy",
+			req: TokenExchangeRequest{
+				ClientID:         "unknown",
+				SubjectToken:     user,
+				SubjectTokenType: TokenTypeAccessToken,
+			},
+			err: ErrUnauthorizedClient,
+		},
+		{
+			name: "invalid subject token",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user + "x",
+				SubjectTokenType: TokenTypeAccessToken,
+			},
+			err: ErrInvalidGrant,
+		},
+		{
+			name: "unsupported subject token type",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     user,
+				SubjectTokenType: "urn:ietf:params:oauth:token-type:id_token",
+			},
+			err: ErrInvalidRequest,
+		},
+		{
+			name: "support impersonates a user",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "3",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			event: entity.SecurityEventImpersonation,
+			sub:   "3",
+			act:   []string{"2"},
+			aud:   []string{"jwt-playground"},
+			scope: "profile:read",
+		},
+		{
+			name: "support impersonates an admin",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "1",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			err: ErrInvalidGrant,
+		},
+		{
+			name: "impersonation by a role the policy does not list",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "3",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       admin,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			err: ErrUnauthorizedClient,
+		},
+		{
+			name: "impersonation through a client without impersonator roles",
+			req: TokenExchangeRequest{
+				ClientID:         "orders",
+				SubjectToken:     "3",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			err: ErrUnauthorizedClient,
+		},
+		{
+			name: "impersonation without an actor",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "3",
+				SubjectTokenType: TokenTypeUserID,
+			},
+			err: ErrInvalidRequest,
+		},
+		{
+			name: "impersonation by a scoped actor",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "3",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       scopedSupport,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			err: ErrInvalidGrant,
+		},
+		{
+			name: "impersonation of an unknown user",
+			req: TokenExchangeRequest{
+				ClientID:         "support-console",
+				SubjectToken:     "404",
+				SubjectTokenType: TokenTypeUserID,
+				ActorToken:       support,
+				ActorTokenType:   TokenTypeAccessToken,
+			},
+			err: ErrInvalidGrant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			recorded := len(x.events.events)
+			res, err := x.exchange.Execute(ctx, tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if len(x.events.events) != recorded {
+					t.Fatalf("failed exchange recorded %d security events", len(x.events.events)-recorded)
+				}
+				return
+			}
+
+			claims, err := x.j.ParseAndVerify(ctx, res.AccessToken)
+			if err != nil {
+				t.Fatalf("exchanged token does not verify: %v", err)
+			}
+			var act []string
+			for a := claims.Actor; a != nil; a = a.Actor {
+				act = append(act, a.Subject)
+			}
+			if claims.Subject != tt.sub ||
+				!slices.Equal(act, tt.act) ||
+				!slices.Equal(claims.Audience, tt.aud) ||
+				claims.Scope != tt.scope ||
+				res.Scope != tt.scope {
+				t.Fatalf(
+					"exchanged token sub=%s act=%v aud=%v scope=%q, want sub=%s act=%v aud=%v scope=%q",
+					claims.Subject, act, claims.Audience, claims.Scope,
+					tt.sub, tt.act, tt.aud, tt.scope,
+				)
+			}
+
+			if len(x.events.events) != recorded+1 {
+				t.Fatalf("recorded %d security events, want 1", len(x.events.events)-recorded)
+			}
+			event := x.events.events[recorded]
+			if event.Type != tt.event ||
+				event.UserID != tt.sub ||
+				event.ActorID != tt.act[0] ||
+				event.ClientID != tt.req.ClientID ||
+				!slices.Equal(event.Audience, tt.aud) ||
+				event.Scope != tt.scope {
+				t.Fatalf("security event = %+v, want a %s event matching the token", event, tt.event)
+			}
+		})
+	}
+}
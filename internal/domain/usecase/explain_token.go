@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+// revocationStep names the revocation check in a verification report.
+const revocationStep = "revocation"
+
+type ExplainTokenUseCase struct {
+	e *env.Env
+	c cache.Cache
+	j *jwtutil.JWTUtil
+}
+
+func NewExplainTokenUseCase(
+	e *env.Env,
+	c cache.Cache,
+	j *jwtutil.JWTUtil,
+) *ExplainTokenUseCase {
+	return &ExplainTokenUseCase{
+		e: e,
+		c: c,
+		j: j,
+	}
+}
+
+// Execute reports every step of access token verification, including the
+// revocation checks made by VerifyAccessTokenUseCase, and whether the token
+// would be accepted.
+func (u *ExplainTokenUseCase) Execute(
+	ctx context.Context,
+	token string,
+) ([]jwtutil.VerificationStep, bool, error) {
+	if !u.e.IsDevelopment() {
+		return nil, false, ErrDebugDisabled
+	}
+
+	steps, claims, err := u.j.Explain(ctx, token)
+	if err != nil {
+		return nil, false, err
+	}
+	if claims == nil {
+		steps = append(steps, jwtutil.VerificationStep{
+			Name:    revocationStep,
+			Skipped: true,
+		})
+		return steps, false, nil
+	}
+
+	err = checkNotRevoked(ctx, u.c, claims)
+	if err != nil && !errors.Is(err, ErrTokenRevoked) {
+		return nil, false, err
+	}
+	step := jwtutil.VerificationStep{Name: revocationStep, OK: err == nil}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	return append(steps, step), err == nil, nil
+}
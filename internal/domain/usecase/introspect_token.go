@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+type IntrospectTokenUseCase struct {
+	vac *VerifyAccessTokenUseCase
+}
+
+func NewIntrospectTokenUseCase(
+	vac *VerifyAccessTokenUseCase,
+) *IntrospectTokenUseCase {
+	return &IntrospectTokenUseCase{
+		vac: vac,
+	}
+}
+
+// Execute reports whether an access token is currently active, as defined
+// by RFC 7662, and returns its claims when it is. Invalid, expired and
+// revoked tokens are simply inactive; an error means the token's state
+// could not be determined.
+func (u *IntrospectTokenUseCase) Execute(
+	ctx context.Context,
+	token string,
+) (*jwtutil.Claims, bool, error) {
+	claims, err := u.vac.Execute(ctx, token)
+	if err != nil {
+		var verr *jwtutil.VerificationError
+		if errors.As(err, &verr) || errors.Is(err, ErrTokenRevoked) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return claims, true, nil
+}
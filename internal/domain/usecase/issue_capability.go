@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+var (
+	// ErrCapabilitiesDisabled is returned when no capability key is
+	// configured.
+	ErrCapabilitiesDisabled = errors.New("capability tokens are disabled")
+	// ErrRestrictedToken is returned when a capability is requested with a
+	// token that is itself restricted or sender-constrained, which would
+	// let it escape its restrictions or its key binding.
+	ErrRestrictedToken = errors.New("restricted tokens cannot issue capabilities")
+	// ErrInvalidCaveat is returned for a caveat that cannot be parsed.
+	ErrInvalidCaveat = errors.New("invalid caveat")
+)
+
+type IssueCapabilityUseCase struct {
+	e *env.Env
+	j *jwtutil.JWTUtil
+}
+
+func NewIssueCapabilityUseCase(
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+) *IssueCapabilityUseCase {
+	return &IssueCapabilityUseCase{
+		e: e,
+		j: j,
+	}
+}
+
+// Execute issues a capability token for the caller, restricted by the given
+// caveats. Only unrestricted bearer access tokens may be used: capability
+// tokens, scoped tokens, tokens acting for someone else and tokens bound to
+// a DPoP key or client certificate are refused. The capability never
+// outlives the token it was issued with.
+func (u *IssueCapabilityUseCase) Execute(
+	claims *jwtutil.Claims,
+	caveats []string,
+) (string, error) {
+	if !u.j.CapabilitiesEnabled() {
+		return "", ErrCapabilitiesDisabled
+	}
+	if claims.Capability || claims.Actor != nil || claims.Scope != "" ||
+		claims.Confirmation != nil {
+		return "", ErrRestrictedToken
+	}
+
+	ttl := u.e.CapabilityTokenTTL
+	if claims.ExpiresAt != nil {
+		ttl = min(ttl, time.Until(claims.ExpiresAt.Time))
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("%w: token has expired", ErrRestrictedToken)
+	}
+
+	for _, c := range caveats {
+		if err := jwtutil.ValidateCaveat(c); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidCaveat, err)
+		}
+	}
+
+	token, err := u.j.MintCapability(
+		jwtutil.TokenSubject{
+			UserID:    claims.Subject,
+			Role:      claims.Role,
+			SessionID: claims.SessionID,
+		},
+		ttl,
+		caveats...,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to mint capability: %w", err)
+	}
+	return token, nil
+}
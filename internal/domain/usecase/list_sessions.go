@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type ListSessionsUseCase struct {
+	c cache.Cache
+}
+
+func NewListSessionsUseCase(
+	c cache.Cache,
+) *ListSessionsUseCase {
+	return &ListSessionsUseCase{
+		c: c,
+	}
+}
+
+// Execute returns the user's active refresh sessions, oldest first.
+// Sessions that have expired or were revoked are left out.
+func (u *ListSessionsUseCase) Execute(
+	ctx context.Context,
+	userID string,
+) ([]entity.RefreshSession, error) {
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+
+	index, err := loadSessionIndex(ctx, u.c, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]entity.RefreshSession, 0, len(index))
+	for _, key := range index {
+		s := entity.RefreshSession{}
+		ok, err := u.c.Scan(ctx, key, &s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refresh session: %w", err)
+		}
+		if !ok || now.After(s.ExpiresAt) {
+			continue
+		}
+		revoked, err := issuedBeforeWatermark(ctx, u.c, s.UserID, s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if !revoked {
+			if revoked, err = refreshSessionRevoked(ctx, u.c, s.ID); err != nil {
+				return nil, err
+			}
+		}
+		if !revoked {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, k int) bool {
+		return sessions[i].CreatedAt.Before(sessions[k].CreatedAt)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+)
+
+type MintTokenUseCase struct {
+	e *env.Env
+	j *jwtutil.JWTUtil
+}
+
+func NewMintTokenUseCase(
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+) *MintTokenUseCase {
+	return &MintTokenUseCase{
+		e: e,
+		j: j,
+	}
+}
+
+// Execute mints a token with arbitrary claims for testing services that
+// consume tokens. A zero TTL defaults to the access token TTL.
+func (u *MintTokenUseCase) Execute(
+	ctx context.Context,
+	spec jwtutil.MintSpec,
+) (string, time.Time, error) {
+	if !u.e.IsDevelopment() {
+		return "", time.Time{}, ErrDebugDisabled
+	}
+	if spec.TTL == 0 {
+		spec.TTL = u.e.AccessTokenTTL
+	}
+
+	token, err := u.j.Mint(ctx, spec)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(spec.TTL), nil
+}
@@ -4,62 +4,210 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/dyegopenha/jwt-playground/internal/config/env"
 	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
 	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
 )
 
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown or
+// expired.
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
 type RefreshUseCase struct {
-	c cache.Cache
-	e *env.Env
-	j *jwtutil.JWTUtil
+	c  cache.Cache
+	e  *env.Env
+	j  *jwtutil.JWTUtil
+	us userstore.UserStore
+	sl securitylog.SecurityLog
 }
 
 func NewRefreshUseCase(
 	c cache.Cache,
 	e *env.Env,
 	j *jwtutil.JWTUtil,
+	us userstore.UserStore,
+	sl securitylog.SecurityLog,
 ) *RefreshUseCase {
 	return &RefreshUseCase{
-		c: c,
-		e: e,
-		j: j,
+		c:  c,
+		e:  e,
+		j:  j,
+		us: us,
+		sl: sl,
 	}
 }
 
+// Execute exchanges a refresh token for a new token pair, moving the session
+// to the new refresh token. cnf holds the DPoP key and TLS certificate the
+// request was made with, if any; sessions bound to either can only be
+// refreshed with the same one, and the new tokens keep the binding. The
+// session keeps its original expiry, which is returned as expiresAt.
+//
+// Every refresh token can be used once. Presenting one that was already
+// rotated revokes its session, including the access tokens issued from it,
+// as recommended by the OAuth 2.0 Security Best Current Practice, and
+// returns ErrRefreshTokenReused.
 func (u *RefreshUseCase) Execute(
 	ctx context.Context,
 	refreshToken string,
-) (accessToken string, newRefreshToken string, err error) {
+	cnf jwtutil.Confirmation,
+	client entity.ClientInfo,
+) (accessToken string, newRefreshToken string, expiresAt time.Time, err error) {
 	refreshSession := entity.RefreshSession{}
-	ok, err := u.c.Scan(ctx, refreshToken, &refreshSession)
+	ok, err := u.c.Scan(ctx, refreshSessionKey(refreshToken), &refreshSession)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to scan refresh token: %w", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to scan refresh token: %w", err)
+	}
+	if !ok {
+		return "", "", time.Time{}, u.checkReuse(ctx, refreshToken, client)
 	}
-	if !ok || time.Now().After(refreshSession.ExpiresAt) {
-		return "", "", errors.New("invalid or expired refresh token")
+	ttl := time.Until(refreshSession.ExpiresAt)
+	if ttl <= 0 {
+		return "", "", time.Time{}, ErrInvalidRefreshToken
 	}
 
-	accessToken, newRefreshToken, err = u.j.IssueTokenPair(
+	revoked, err := issuedBeforeWatermark(
+		ctx,
+		u.c,
 		refreshSession.UserID,
-		refreshSession.Role,
+		refreshSession.CreatedAt,
+	)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if !revoked {
+		if revoked, err = refreshSessionRevoked(ctx, u.c, refreshSession.ID); err != nil {
+			return "", "", time.Time{}, err
+		}
+	}
+	if revoked {
+		return "", "", time.Time{}, ErrTokenRevoked
+	}
+	if refreshSession.JKT != "" && refreshSession.JKT != cnf.JKT {
+		return "", "", time.Time{}, ErrDPoPKeyMismatch
+	}
+	if refreshSession.X5TS256 != "" && refreshSession.X5TS256 != cnf.X5TS256 {
+		return "", "", time.Time{}, ErrCertificateMismatch
+	}
+
+	// Mark the token as rotated before rotating it, so that of two requests
+	// presenting it only the first gets new tokens and the second is
+	// treated as reuse.
+	rotated := rotatedRefreshToken{
+		SessionID: refreshSession.ID,
+		UserID:    refreshSession.UserID,
+	}
+	rotatedKey := rotatedRefreshTokenKey(refreshToken)
+	claimed, err := u.c.SetNX(ctx, rotatedKey, rotated, ttl)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !claimed {
+		return "", "", time.Time{}, u.revokeReused(ctx, rotated, client)
+	}
+	defer func() {
+		// Let the client retry with the same token after a failure that
+		// left it without new tokens, rather than treat the retry as reuse.
+		if err == nil {
+			return
+		}
+		if derr := u.c.Delete(ctx, rotatedKey); derr != nil {
+			log.Printf("failed to release refresh token: %v", derr)
+		}
+	}()
+
+	// Reload the user so the new access token reflects the current record.
+	user, err := u.us.FindByID(ctx, refreshSession.UserID)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	sub := tokenSubject(user, jwtutil.Confirmation{
+		JKT:     refreshSession.JKT,
+		X5TS256: refreshSession.X5TS256,
+	})
+	sub.SessionID = refreshSession.ID
+	accessToken, newRefreshToken, err = u.j.IssueTokenPair(
+		ctx,
+		sub,
 		u.e.AccessTokenTTL,
 		u.e.RefreshTokenTTL,
 	)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to issue token pair: %w", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to issue token pair: %w", err)
 	}
 
-	if err := u.c.Set(ctx, newRefreshToken, refreshSession, u.e.RefreshTokenTTL); err != nil {
-		return "", "", fmt.Errorf("failed to set refresh token: %w", err)
+	refreshSession.Role = user.Role
+	touchRefreshSession(&refreshSession, client, time.Now())
+	if err := saveRefreshSession(
+		ctx,
+		u.c,
+		newRefreshToken,
+		refreshSession,
+	); err != nil {
+		return "", "", time.Time{}, err
 	}
 
-	if err := u.c.Delete(ctx, refreshToken); err != nil {
-		return "", "", fmt.Errorf("failed to invalidate refresh token: %w", err)
+	// The new session is saved, so the rotation stands even if the old
+	// session cannot be deleted: the rotated marker kept for the old token
+	// still makes presenting it again count as reuse.
+	if err := u.c.Delete(ctx, refreshSessionKey(refreshToken)); err != nil {
+		log.Printf("failed to invalidate refresh token: %v", err)
+	}
+
+	return accessToken, newRefreshToken, refreshSession.ExpiresAt, nil
+}
+
+// checkReuse is called for a refresh token that has no session. It returns
+// ErrRefreshTokenReused, after revoking the session, when the token was
+// rotated before, and ErrInvalidRefreshToken otherwise.
+func (u *RefreshUseCase) checkReuse(
+	ctx context.Context,
+	refreshToken string,
+	client entity.ClientInfo,
+) error {
+	rotated := rotatedRefreshToken{}
+	ok, err := u.c.Scan(ctx, rotatedRefreshTokenKey(refreshToken), &rotated)
+	if err != nil {
+		return fmt.Errorf("failed to scan rotated refresh token: %w", err)
+	}
+	if !ok {
+		return ErrInvalidRefreshToken
 	}
+	return u.revokeReused(ctx, rotated, client)
+}
+
+// revokeReused revokes the session of a reused refresh token and records a
+// security event. Both the legitimate client and whoever else holds a token
+// of the session have to sign in again.
+func (u *RefreshUseCase) revokeReused(
+	ctx context.Context,
+	rotated rotatedRefreshToken,
+	client entity.ClientInfo,
+) error {
+	u.sl.Record(ctx, entity.SecurityEvent{
+		Type:      entity.SecurityEventRefreshTokenReuse,
+		Time:      time.Now(),
+		UserID:    rotated.UserID,
+		SessionID: rotated.SessionID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
 
-	return accessToken, newRefreshToken, nil
+	if err := revokeRefreshSession(
+		ctx,
+		u.c,
+		u.e,
+		rotated.UserID,
+		rotated.SessionID,
+	); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
 }
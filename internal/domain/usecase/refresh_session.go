@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+var (
+	// ErrSessionNotFound is returned for a refresh session that does not
+	// exist or has already expired.
+	ErrSessionNotFound = errors.New("refresh session not found")
+	// ErrRefreshTokenReused is returned when a refresh token that was
+	// already rotated is presented again. Its session has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// refreshSessionKey is the cache key of the session a refresh token belongs
+// to. Only a hash of the token is stored, so the cache never holds usable
+// refresh tokens.
+func refreshSessionKey(refreshToken string) string {
+	return "refresh:" + hashRefreshToken(refreshToken)
+}
+
+// rotatedRefreshTokenKey is the cache key recording that a refresh token
+// has been rotated, and which session it belonged to.
+func rotatedRefreshTokenKey(refreshToken string) string {
+	return "refresh:rotated:" + hashRefreshToken(refreshToken)
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// revokedSessionKey is set once a session has been revoked, so that none
+// of its refresh or access tokens is accepted again.
+func revokedSessionKey(sessionID string) string {
+	return "refresh:revoked:" + sessionID
+}
+
+// sessionIndexPrefix is the common prefix of the user's session index
+// entries. Each session has its own entry, holding the cache key of the
+// session's current refresh token, so sessions are added and removed
+// without touching the others.
+func sessionIndexPrefix(userID string) string {
+	return "refresh:sessions:" + userID + ":"
+}
+
+func sessionIndexKey(userID, sessionID string) string {
+	return sessionIndexPrefix(userID) + sessionID
+}
+
+// globEscaper escapes the characters cache.Cache.Keys patterns treat as
+// special.
+var globEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"?", `\?`,
+	"[", `\[`,
+)
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// loadSessionIndex maps the ID of each of the user's sessions to the cache
+// key of its current refresh token.
+func loadSessionIndex(
+	ctx context.Context,
+	c cache.Cache,
+	userID string,
+) (map[string]string, error) {
+	prefix := sessionIndexPrefix(userID)
+	keys, err := c.Keys(ctx, globEscaper.Replace(prefix)+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	index := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sessionID := strings.TrimPrefix(key, prefix)
+		// Session IDs never contain a colon, but the entries of a user
+		// whose ID starts with this user's ID and a colon would.
+		if strings.Contains(sessionID, ":") {
+			continue
+		}
+		var refreshKey string
+		ok, err := c.Scan(ctx, key, &refreshKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session index: %w", err)
+		}
+		if ok {
+			index[sessionID] = refreshKey
+		}
+	}
+	return index, nil
+}
+
+// saveRefreshSession stores the session under its refresh token, and points
+// the session's index entry at it, until the session expires.
+func saveRefreshSession(
+	ctx context.Context,
+	c cache.Cache,
+	refreshToken string,
+	s entity.RefreshSession,
+) error {
+	ttl := time.Until(s.ExpiresAt)
+	if ttl <= 0 {
+		return ErrInvalidRefreshToken
+	}
+
+	key := refreshSessionKey(refreshToken)
+	if err := c.Set(ctx, key, s, ttl); err != nil {
+		return fmt.Errorf("failed to set refresh token: %w", err)
+	}
+	if err := c.Set(ctx, sessionIndexKey(s.UserID, s.ID), key, ttl); err != nil {
+		return fmt.Errorf("failed to update session index: %w", err)
+	}
+	return nil
+}
+
+// maxUserAgentBytes bounds the user agent stored with a session.
+const maxUserAgentBytes = 256
+
+// touchRefreshSession records that client used the session at now.
+func touchRefreshSession(
+	s *entity.RefreshSession,
+	client entity.ClientInfo,
+	now time.Time,
+) {
+	s.LastUsedAt = now
+	s.IP = client.IP
+	s.UserAgent = client.UserAgent
+	if len(s.UserAgent) > maxUserAgentBytes {
+		s.UserAgent = strings.ToValidUTF8(s.UserAgent[:maxUserAgentBytes], "")
+	}
+}
+
+// rotatedRefreshToken is stored for every refresh token replaced by
+// rotation, for as long as its session lives. Each session is a refresh
+// token family: presenting a token that was already rotated means it was
+// stolen or leaked, and the whole family is revoked.
+type rotatedRefreshToken struct {
+	SessionID string
+	UserID    string
+}
+
+// revokeRefreshSession makes sure neither the refresh tokens nor the access
+// tokens of the session are accepted again: the session is marked as
+// revoked, and its current refresh token and index entry are deleted.
+func revokeRefreshSession(
+	ctx context.Context,
+	c cache.Cache,
+	e *env.Env,
+	userID, sessionID string,
+) error {
+	if err := c.Set(ctx, revokedSessionKey(sessionID), true, watermarkTTL(e)); err != nil {
+		return fmt.Errorf("failed to revoke refresh session: %w", err)
+	}
+
+	indexKey := sessionIndexKey(userID, sessionID)
+	var refreshKey string
+	ok, err := c.Scan(ctx, indexKey, &refreshKey)
+	if err != nil {
+		return fmt.Errorf("failed to load session index: %w", err)
+	}
+	keys := []string{indexKey}
+	if ok {
+		keys = append(keys, refreshKey)
+	}
+	if err := c.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to delete refresh session: %w", err)
+	}
+	return nil
+}
+
+// refreshSessionRevoked reports whether the session has been revoked by
+// revokeRefreshSession. Access tokens issued from the session are revoked
+// along with it.
+func refreshSessionRevoked(
+	ctx context.Context,
+	c cache.Cache,
+	sessionID string,
+) (bool, error) {
+	var revoked bool
+	ok, err := c.Scan(ctx, revokedSessionKey(sessionID), &revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check refresh session revocation: %w", err)
+	}
+	return ok && revoked, nil
+}
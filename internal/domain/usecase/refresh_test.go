@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	memorycache "github.com/dyegopenha/jwt-playground/internal/provider/cache/memory"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore/memory"
+)
+
+// securityEvents is a securitylog.SecurityLog that keeps the events.
+type securityEvents struct {
+	mu     sync.Mutex
+	events []entity.SecurityEvent
+}
+
+func (s *securityEvents) Record(_ context.Context, event entity.SecurityEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+type sessionUseCases struct {
+	signIn  *SignInUseCase
+	refresh *RefreshUseCase
+	verify  *VerifyAccessTokenUseCase
+	list    *ListSessionsUseCase
+	revoke  *RevokeSessionUseCase
+	events  *securityEvents
+}
+
+// failingDeletes is a cache.Cache whose Delete fails when it includes the
+// key fail.
+type failingDeletes struct {
+	cache.Cache
+	fail string
+}
+
+func (c *failingDeletes) Delete(ctx context.Context, keys ...string) error {
+	if slices.Contains(keys, c.fail) {
+		return errors.New("delete failed")
+	}
+	return c.Cache.Delete(ctx, keys...)
+}
+
+func newSessionUseCases(t *testing.T) *sessionUseCases {
+	t.Helper()
+
+	return newSessionUseCasesWithCache(t, memorycache.NewMemory())
+}
+
+func newSessionUseCasesWithCache(t *testing.T, c cache.Cache) *sessionUseCases {
+	t.Helper()
+
+	e := &env.Env{
+		Environment:       env.EnvironmentTest,
+		JWTAlgorithm:      "HS256",
+		HMACKey:           "test-hmac-secret-that-is-long-enough",
+		JWTIssuer:         "jwt-playground",
+		JWTAudience:       []string{"jwt-playground-api"},
+		JWTClaimsMaxBytes: 1024,
+		AccessTokenTTL:    time.Minute,
+		RefreshTokenTTL:   time.Hour,
+		TokenFormat:       jwtutil.TokenFormatJWT,
+	}
+	j := jwtutil.NewJWTUtil(e, c)
+	us := memory.NewMemory()
+	events := &securityEvents{}
+	return &sessionUseCases{
+		signIn:  NewSignInUseCase(e, c, j, us),
+		refresh: NewRefreshUseCase(c, e, j, us, events),
+		verify:  NewVerifyAccessTokenUseCase(c, j),
+		list:    NewListSessionsUseCase(c),
+		revoke:  NewRevokeSessionUseCase(c, e),
+		events:  events,
+	}
+}
+
+var (
+	legitimateClient = entity.ClientInfo{IP: "10.0.0.1", UserAgent: "app/1.0"}
+	attacker         = entity.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8"}
+)
+
+func (u *sessionUseCases) mustSignIn(t *testing.T) (accessToken, refreshToken, sessionID string) {
+	t.Helper()
+
+	ctx := context.Background()
+	accessToken, refreshToken, _, err := u.signIn.Execute(
+		ctx,
+		"test@example.com",
+		"password",
+		jwtutil.Confirmation{},
+		legitimateClient,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := u.verify.Execute(ctx, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID == "" {
+		t.Fatal("access token has no sid claim")
+	}
+	return accessToken, refreshToken, claims.SessionID
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	u := newSessionUseCases(t)
+	ctx := context.Background()
+
+	access1, refresh1, sessionID := u.mustSignIn(t)
+	otherAccess, _, otherSessionID := u.mustSignIn(t)
+
+	access2, refresh2, _, err := u.refresh.Execute(ctx, refresh1, jwtutil.Confirmation{}, legitimateClient)
+	if err != nil {
+		t.Fatalf("refresh error = %v", err)
+	}
+
+	// The rotated token is replayed, e.g. by someone who stole it.
+	_, _, _, err = u.refresh.Execute(ctx, refresh1, jwtutil.Confirmation{}, attacker)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if len(u.events.events) != 1 {
+		t.Fatalf("recorded %d security events, want 1", len(u.events.events))
+	}
+	got := u.events.events[0]
+	want := entity.SecurityEvent{
+		Type:      entity.SecurityEventRefreshTokenReuse,
+		Time:      got.Time,
+		UserID:    "1",
+		SessionID: sessionID,
+		IP:        attacker.IP,
+		UserAgent: attacker.UserAgent,
+	}
+	if !reflect.DeepEqual(got, want) || time.Since(got.Time) > time.Minute {
+		t.Fatalf("security event = %+v, want %+v", got, want)
+	}
+
+	// Every token of the family is revoked.
+	if _, _, _, err := u.refresh.Execute(ctx, refresh2, jwtutil.Confirmation{}, legitimateClient); err == nil {
+		t.Fatal("refresh with the current token of a revoked session succeeded")
+	}
+	for _, token := range []string{access1, access2} {
+		if _, err := u.verify.Execute(ctx, token); !errors.Is(err, ErrTokenRevoked) {
+			t.Fatalf("access token of revoked session error = %v, want ErrTokenRevoked", err)
+		}
+	}
+
+	// Other sessions of the user are left alone.
+	if _, err := u.verify.Execute(ctx, otherAccess); err != nil {
+		t.Fatalf("access token of another session error = %v", err)
+	}
+	sessions, err := u.list.Execute(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != otherSessionID {
+		t.Fatalf("sessions = %+v, want only %s", sessions, otherSessionID)
+	}
+}
+
+func TestRefreshKeepsRotationWhenOldSessionIsNotDeleted(t *testing.T) {
+	c := &failingDeletes{Cache: memorycache.NewMemory()}
+	u := newSessionUseCasesWithCache(t, c)
+	ctx := context.Background()
+
+	_, refresh1, _ := u.mustSignIn(t)
+	c.fail = refreshSessionKey(refresh1)
+
+	_, refresh2, _, err := u.refresh.Execute(ctx, refresh1, jwtutil.Confirmation{}, legitimateClient)
+	if err != nil {
+		t.Fatalf("refresh error = %v", err)
+	}
+
+	// The old session is still stored, but the token was rotated and must
+	// not yield a second set of tokens.
+	if _, _, _, err := u.refresh.Execute(ctx, refresh1, jwtutil.Confirmation{}, attacker); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse error = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, _, _, err := u.refresh.Execute(ctx, refresh2, jwtutil.Confirmation{}, legitimateClient); err == nil {
+		t.Fatal("refresh of a revoked session succeeded")
+	}
+}
+
+func TestRevokeSessionRevokesAccessTokens(t *testing.T) {
+	u := newSessionUseCases(t)
+	ctx := context.Background()
+
+	access, refresh, sessionID := u.mustSignIn(t)
+
+	if err := u.revoke.Execute(ctx, "1", sessionID); err != nil {
+		t.Fatalf("revoke error = %v", err)
+	}
+	if _, err := u.verify.Execute(ctx, access); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access token error = %v, want ErrTokenRevoked", err)
+	}
+	if _, _, _, err := u.refresh.Execute(ctx, refresh, jwtutil.Confirmation{}, legitimateClient); err == nil {
+		t.Fatal("refresh of a revoked session succeeded")
+	}
+	if err := u.revoke.Execute(ctx, "1", sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("second revoke error = %v, want ErrSessionNotFound", err)
+	}
+	if len(u.events.events) != 0 {
+		t.Fatalf("recorded %+v for an explicit revocation", u.events.events)
+	}
+}
+
+func TestConcurrentSessionsAreIndexed(t *testing.T) {
+	u := newSessionUseCases(t)
+	ctx := context.Background()
+
+	const n = 20
+	refreshTokens := make([]string, n)
+	for i := range refreshTokens {
+		_, refreshTokens[i], _ = u.mustSignIn(t)
+	}
+
+	// Sign-ins and refreshes of the same user race to update the index.
+	var wg sync.WaitGroup
+	for _, token := range refreshTokens {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := u.refresh.Execute(ctx, token, jwtutil.Confirmation{}, legitimateClient); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := u.signIn.Execute(
+				ctx,
+				"test@example.com",
+				"password",
+				jwtutil.Confirmation{},
+				legitimateClient,
+			); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sessions, err := u.list.Execute(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2*n {
+		t.Fatalf("listed %d sessions, want %d", len(sessions), 2*n)
+	}
+}
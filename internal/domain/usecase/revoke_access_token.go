@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type RevokeAccessTokenUseCase struct {
+	c cache.Cache
+	e *env.Env
+	j *jwtutil.JWTUtil
+}
+
+func NewRevokeAccessTokenUseCase(
+	c cache.Cache,
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+) *RevokeAccessTokenUseCase {
+	return &RevokeAccessTokenUseCase{
+		c: c,
+		e: e,
+		j: j,
+	}
+}
+
+// Execute adds the token's jti to the denylist until the token expires.
+// Only valid tokens issued by this server can be revoked.
+func (u *RevokeAccessTokenUseCase) Execute(
+	ctx context.Context,
+	accessToken string,
+) error {
+	claims, err := u.j.ParseAndVerify(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return errors.New("token has no jti")
+	}
+
+	// Keep the entry for as long as the token could still be accepted.
+	ttl := time.Until(claims.ExpiresAt.Time) + u.e.JWTLeeway
+	if err := u.c.Set(ctx, revokedTokenKey(claims.ID), true, ttl); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+func revokedTokenKey(jti string) string {
+	return "revoked:jti:" + jti
+}
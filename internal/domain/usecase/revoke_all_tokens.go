@@ -0,0 +1,29 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type RevokeAllTokensUseCase struct {
+	c cache.Cache
+	e *env.Env
+}
+
+func NewRevokeAllTokensUseCase(
+	c cache.Cache,
+	e *env.Env,
+) *RevokeAllTokensUseCase {
+	return &RevokeAllTokensUseCase{
+		c: c,
+		e: e,
+	}
+}
+
+// Execute invalidates every access and refresh token issued so far, for
+// every user, e.g. after a signing key leak.
+func (u *RevokeAllTokensUseCase) Execute(ctx context.Context) error {
+	return setWatermark(ctx, u.c, globalWatermarkKey, watermarkTTL(u.e))
+}
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type RevokeSessionUseCase struct {
+	c cache.Cache
+	e *env.Env
+}
+
+func NewRevokeSessionUseCase(
+	c cache.Cache,
+	e *env.Env,
+) *RevokeSessionUseCase {
+	return &RevokeSessionUseCase{
+		c: c,
+		e: e,
+	}
+}
+
+// Execute revokes one of the user's refresh sessions, so that neither its
+// refresh tokens nor the access tokens issued from it are accepted again.
+func (u *RevokeSessionUseCase) Execute(
+	ctx context.Context,
+	userID, sessionID string,
+) error {
+	if userID == "" || sessionID == "" {
+		return errors.New("user id and session id are required")
+	}
+
+	var refreshKey string
+	ok, err := u.c.Scan(ctx, sessionIndexKey(userID, sessionID), &refreshKey)
+	if err != nil {
+		return fmt.Errorf("failed to load session index: %w", err)
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return revokeRefreshSession(ctx, u.c, u.e, userID, sessionID)
+}
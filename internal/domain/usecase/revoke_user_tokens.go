@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type RevokeUserTokensUseCase struct {
+	c cache.Cache
+	e *env.Env
+}
+
+func NewRevokeUserTokensUseCase(
+	c cache.Cache,
+	e *env.Env,
+) *RevokeUserTokensUseCase {
+	return &RevokeUserTokensUseCase{
+		c: c,
+		e: e,
+	}
+}
+
+// Execute invalidates every access and refresh token issued to the user
+// so far, e.g. after a password change or account compromise.
+func (u *RevokeUserTokensUseCase) Execute(
+	ctx context.Context,
+	userID string,
+) error {
+	if userID == "" {
+		return errors.New("user id is required")
+	}
+	return setWatermark(ctx, u.c, userWatermarkKey(userID), watermarkTTL(u.e))
+}
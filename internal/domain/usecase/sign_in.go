@@ -3,54 +3,101 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
 	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
 	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
 )
 
 type SignInUseCase struct {
-	e *env.Env
-	c cache.Cache
-	j *jwtutil.JWTUtil
+	e  *env.Env
+	c  cache.Cache
+	j  *jwtutil.JWTUtil
+	us userstore.UserStore
 }
 
 func NewSignInUseCase(
 	e *env.Env,
 	c cache.Cache,
 	j *jwtutil.JWTUtil,
+	us userstore.UserStore,
 ) *SignInUseCase {
 	return &SignInUseCase{
-		e: e,
-		c: c,
-		j: j,
+		e:  e,
+		c:  c,
+		j:  j,
+		us: us,
 	}
 }
 
+// Execute signs the user in and starts a refresh session for client. Both
+// tokens are bound to the client's DPoP key or TLS certificate named in cnf,
+// if any. expiresAt is when the session ends.
 func (u *SignInUseCase) Execute(
 	ctx context.Context,
 	email, password string,
-) (accessToken string, refreshToken string, err error) {
-	// TODO: Get user from database and verify password
-	user := map[string]string{
-		"id":    "1",
-		"email": "test@example.com",
-		"role":  "admin",
+	cnf jwtutil.Confirmation,
+	client entity.ClientInfo,
+) (accessToken string, refreshToken string, expiresAt time.Time, err error) {
+	// TODO: Verify password
+	user, err := u.us.FindByEmail(ctx, email)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		return "", "", time.Time{}, err
 	}
 
+	sub := tokenSubject(user, cnf)
+	sub.SessionID = sessionID
 	accessToken, refreshToken, err = u.j.IssueTokenPair(
-		user["id"],
-		user["role"],
+		ctx,
+		sub,
 		u.e.AccessTokenTTL,
 		u.e.RefreshTokenTTL,
 	)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to issue token pair: %w", err)
+		return "", "", time.Time{}, fmt.Errorf("failed to issue token pair: %w", err)
 	}
 
-	if err := u.c.Set(ctx, refreshToken, user, u.e.RefreshTokenTTL); err != nil {
-		return "", "", fmt.Errorf("failed to set refresh token: %w", err)
+	now := time.Now()
+	refreshSession := entity.RefreshSession{
+		ID:        sessionID,
+		UserID:    user.ID,
+		Role:      user.Role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.e.RefreshTokenTTL),
+		JKT:       cnf.JKT,
+		X5TS256:   cnf.X5TS256,
 	}
+	touchRefreshSession(&refreshSession, client, now)
+	if err := saveRefreshSession(
+		ctx,
+		u.c,
+		refreshToken,
+		refreshSession,
+	); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	return accessToken, refreshToken, refreshSession.ExpiresAt, nil
+}
 
-	return accessToken, refreshToken, nil
+// tokenSubject describes the user an access token is issued to, bound to
+// the keys in cnf.
+func tokenSubject(
+	user *entity.User,
+	cnf jwtutil.Confirmation,
+) jwtutil.TokenSubject {
+	return jwtutil.TokenSubject{
+		UserID:       user.ID,
+		Role:         user.Role,
+		Attributes:   user.ClaimAttributes(),
+		Confirmation: cnf,
+	}
 }
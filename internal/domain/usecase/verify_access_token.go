@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+// ErrTokenRevoked is returned for a valid token that has been revoked,
+// either individually, with its refresh session or by a "tokens issued
+// before" watermark.
+var ErrTokenRevoked = errors.New("token revoked")
+
+type VerifyAccessTokenUseCase struct {
+	c cache.Cache
+	j *jwtutil.JWTUtil
+}
+
+func NewVerifyAccessTokenUseCase(
+	c cache.Cache,
+	j *jwtutil.JWTUtil,
+) *VerifyAccessTokenUseCase {
+	return &VerifyAccessTokenUseCase{
+		c: c,
+		j: j,
+	}
+}
+
+// Execute verifies an access token and makes sure it has not been revoked,
+// neither by jti, nor with its refresh session, nor by a user or global
+// watermark.
+func (u *VerifyAccessTokenUseCase) Execute(
+	ctx context.Context,
+	accessToken string,
+) (*jwtutil.Claims, error) {
+	claims, err := u.j.ParseAndVerify(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotRevoked(ctx, u.c, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkNotRevoked returns ErrTokenRevoked when the token described by claims
+// has been revoked by jti or with its refresh session, or predates a user or
+// global watermark.
+func checkNotRevoked(
+	ctx context.Context,
+	c cache.Cache,
+	claims *jwtutil.Claims,
+) error {
+	var revoked bool
+	ok, err := c.Scan(ctx, revokedTokenKey(claims.ID), &revoked)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if ok && revoked {
+		return ErrTokenRevoked
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := refreshSessionRevoked(ctx, c, claims.SessionID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	before, err := issuedBeforeWatermark(ctx, c, claims.Subject, issuedAt)
+	if err != nil {
+		return err
+	}
+	if before {
+		return ErrTokenRevoked
+	}
+	return nil
+}
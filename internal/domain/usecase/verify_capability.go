@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type VerifyCapabilityUseCase struct {
+	c cache.Cache
+	j *jwtutil.JWTUtil
+}
+
+func NewVerifyCapabilityUseCase(
+	c cache.Cache,
+	j *jwtutil.JWTUtil,
+) *VerifyCapabilityUseCase {
+	return &VerifyCapabilityUseCase{
+		c: c,
+		j: j,
+	}
+}
+
+// Execute verifies a capability token and its caveats against the request,
+// and makes sure it has not been revoked like any other access token.
+func (u *VerifyCapabilityUseCase) Execute(
+	ctx context.Context,
+	token string,
+	req jwtutil.CapabilityRequest,
+) (*jwtutil.Claims, error) {
+	claims, err := u.j.VerifyCapability(token, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNotRevoked(ctx, u.c, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
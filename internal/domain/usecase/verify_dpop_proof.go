@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+// ErrDPoPProofReplayed is returned for a DPoP proof whose jti has already
+// been used.
+var ErrDPoPProofReplayed = errors.New("dpop proof replayed")
+
+type VerifyDPoPProofUseCase struct {
+	c cache.Cache
+	e *env.Env
+	j *jwtutil.JWTUtil
+}
+
+func NewVerifyDPoPProofUseCase(
+	c cache.Cache,
+	e *env.Env,
+	j *jwtutil.JWTUtil,
+) *VerifyDPoPProofUseCase {
+	return &VerifyDPoPProofUseCase{
+		c: c,
+		e: e,
+		j: j,
+	}
+}
+
+// Execute verifies a DPoP proof for a request and makes sure it has not
+// been used before. accessToken is empty for proofs sent to the token
+// endpoints.
+func (u *VerifyDPoPProofUseCase) Execute(
+	ctx context.Context,
+	proof, method, url, accessToken string,
+) (*jwtutil.DPoPProof, error) {
+	p, err := u.j.VerifyDPoPProof(proof, method, url, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the jti for as long as the proof could still be accepted.
+	ttl := u.e.DPoPProofMaxAge + 2*u.e.JWTLeeway
+	fresh, err := u.c.SetNX(ctx, dpopProofKey(p.JTI), true, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record dpop proof: %w", err)
+	}
+	if !fresh {
+		return nil, ErrDPoPProofReplayed
+	}
+
+	return p, nil
+}
+
+func dpopProofKey(jti string) string {
+	return "dpop:jti:" + jti
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/pkg/jwtutil"
+	memorycache "github.com/dyegopenha/jwt-playground/internal/provider/cache/memory"
+)
+
+const testDPoPURL = "https://auth.example.com/refresh"
+
+func signDPoPProof(t *testing.T, k *ecdsa.PrivateKey, jti string) string {
+	t.Helper()
+
+	jwk, err := jwtutil.NewJWK(&k.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"jti": jti,
+		"htm": "POST",
+		"htu": testDPoPURL,
+		"iat": time.Now().Unix(),
+	})
+	tok.Header["typ"] = "dpop+jwt"
+	tok.Header["jwk"] = jwk
+	s, err := tok.SignedString(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVerifyDPoPProofRejectsReplays(t *testing.T) {
+	e := &env.Env{
+		Environment:       env.EnvironmentTest,
+		JWTAlgorithm:      "HS256",
+		HMACKey:           "test-hmac-secret-that-is-long-enough",
+		JWTIssuer:         "jwt-playground",
+		JWTAudience:       []string{"jwt-playground-api"},
+		JWTClaimsMaxBytes: 1024,
+		AccessTokenTTL:    time.Minute,
+		DPoPProofMaxAge:   time.Minute,
+		TokenFormat:       jwtutil.TokenFormatJWT,
+	}
+	c := memorycache.NewMemory()
+	vdc := NewVerifyDPoPProofUseCase(c, e, jwtutil.NewJWTUtil(e, c))
+	ctx := context.Background()
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		proof string
+		want  error
+	}{
+		{"first use", signDPoPProof(t, k, "proof-1"), nil},
+		{"replayed", signDPoPProof(t, k, "proof-1"), ErrDPoPProofReplayed},
+		{"replayed by another key", signDPoPProof(t, other, "proof-1"), ErrDPoPProofReplayed},
+		{"fresh jti", signDPoPProof(t, k, "proof-2"), nil},
+		{"invalid proof does not burn its jti", signDPoPProof(t, k, "proof-3")[1:], jwtutil.ErrInvalidDPoPProof},
+		{"jti of an invalid proof", signDPoPProof(t, k, "proof-3"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := vdc.Execute(ctx, tt.proof, "POST", testDPoPURL, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
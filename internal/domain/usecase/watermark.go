@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+const globalWatermarkKey = "revoked:before:global"
+
+func userWatermarkKey(userID string) string {
+	return "revoked:before:user:" + userID
+}
+
+// issuedBeforeWatermark reports whether a credential issued at issuedAt for
+// userID predates the user's or the global "tokens issued before" watermark.
+// Watermarks have second precision, like the iat claim, so a credential
+// issued in the same second as a watermark is also rejected. A zero
+// issuedAt is treated as issued before any watermark.
+func issuedBeforeWatermark(
+	ctx context.Context,
+	c cache.Cache,
+	userID string,
+	issuedAt time.Time,
+) (bool, error) {
+	for _, key := range []string{globalWatermarkKey, userWatermarkKey(userID)} {
+		var watermark int64
+		ok, err := c.Scan(ctx, key, &watermark)
+		if err != nil {
+			return false, fmt.Errorf("failed to check revocation watermark: %w", err)
+		}
+		if ok && issuedAt.Unix() <= watermark {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setWatermark records the current time under key for as long as any
+// credential issued before it could still be accepted.
+func setWatermark(
+	ctx context.Context,
+	c cache.Cache,
+	key string,
+	ttl time.Duration,
+) error {
+	if err := c.Set(ctx, key, time.Now().Unix(), ttl); err != nil {
+		return fmt.Errorf("failed to set revocation watermark: %w", err)
+	}
+	return nil
+}
+
+// watermarkTTL is the longest time a credential issued before a watermark
+// could still be accepted.
+func watermarkTTL(e *env.Env) time.Duration {
+	return max(e.AccessTokenTTL, e.RefreshTokenTTL, e.CapabilityTokenTTL) + e.JWTLeeway
+}
@@ -0,0 +1,309 @@
+package jwtutil
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Capability tokens are macaroon-style bearer tokens: an identifier signed
+// by the server, followed by caveats that each restrict the token further.
+// Every caveat is chained into the signature with HMAC, keyed by the
+// previous signature, so whoever holds a token can add caveats offline but
+// nobody can remove them without the server's key.
+//
+// The encoding is "cap." followed by the base64url identifier, each
+// caveat and the signature, separated by dots.
+const capabilityPrefix = "cap."
+
+// Caveat keys and operators, e.g. "method in GET,HEAD", "path prefix /docs/"
+// or "time < 2030-01-01T00:00:00Z".
+const (
+	caveatMethod = "method"
+	caveatPath   = "path"
+	caveatTime   = "time"
+)
+
+// capabilityIdentifier is the part of a capability token set by the server.
+type capabilityIdentifier struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// CapabilityRequest is the request a capability token's caveats are
+// checked against.
+type CapabilityRequest struct {
+	Method string
+	Path   string
+	Time   time.Time
+}
+
+// caveat is a parsed caveat.
+type caveat struct {
+	key, op, value string
+}
+
+// loadCapabilityKey returns the root key of capability tokens, or nil when
+// they are disabled.
+func loadCapabilityKey(e *env.Env) ([]byte, error) {
+	if e.CapabilityKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(e.CapabilityKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode capability key: %w", err)
+	}
+	if len(key) < 32 {
+		return nil, errors.New("capability key must be at least 32 bytes")
+	}
+	return key, nil
+}
+
+// IsCapability reports whether a token is a capability token.
+func IsCapability(token string) bool {
+	return strings.HasPrefix(token, capabilityPrefix)
+}
+
+// CapabilitiesEnabled reports whether a capability key is configured.
+func (j *JWTUtil) CapabilitiesEnabled() bool {
+	return j.capKey != nil
+}
+
+// MintCapability issues a capability token for the subject, valid for ttl
+// and restricted by the given caveats.
+func (j *JWTUtil) MintCapability(
+	sub TokenSubject,
+	ttl time.Duration,
+	caveats ...string,
+) (string, error) {
+	if j.capKey == nil {
+		return "", errors.New("capability tokens are disabled")
+	}
+
+	jti, err := generateRandomBase64(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	id, err := json.Marshal(capabilityIdentifier{
+		Issuer:    j.e.JWTIssuer,
+		Subject:   sub.UserID,
+		Role:      sub.Role,
+		ID:        jti,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		SessionID: sub.SessionID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, j.capKey)
+	mac.Write(id)
+	root := capabilityPrefix +
+		b64.EncodeToString(id) + "." +
+		b64.EncodeToString(mac.Sum(nil))
+	return Attenuate(root, caveats...)
+}
+
+// Attenuate adds caveats to a capability token. It needs no key, so holders
+// can derive restricted tokens offline before handing them to others.
+func Attenuate(token string, caveats ...string) (string, error) {
+	parts, err := splitCapability(token)
+	if err != nil {
+		return "", err
+	}
+	sig := parts[len(parts)-1]
+	body := parts[:len(parts)-1]
+
+	for _, c := range caveats {
+		if _, err := parseCaveat(c); err != nil {
+			return "", err
+		}
+		sig = chainCaveat(sig, []byte(c))
+		body = append(body, []byte(c))
+	}
+
+	encoded := make([]string, 0, len(body)+1)
+	for _, p := range append(body, sig) {
+		encoded = append(encoded, b64.EncodeToString(p))
+	}
+	return capabilityPrefix + strings.Join(encoded, "."), nil
+}
+
+// VerifyCapability checks a capability token's signature chain and every
+// caveat against the request, and returns the claims it stands for.
+// Unknown caveats fail verification. Errors are always a
+// *VerificationError.
+func (j *JWTUtil) VerifyCapability(
+	token string,
+	req CapabilityRequest,
+) (*Claims, error) {
+	if j.capKey == nil {
+		return nil, verificationError(ErrUnknownKey, nil)
+	}
+	if len(token) > maxTokenBytes {
+		return nil, verificationError(ErrTokenTooLarge, nil)
+	}
+	parts, err := splitCapability(token)
+	if err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+
+	id, caveats, sig := parts[0], parts[1:len(parts)-1], parts[len(parts)-1]
+	mac := hmac.New(sha256.New, j.capKey)
+	mac.Write(id)
+	want := mac.Sum(nil)
+	for _, c := range caveats {
+		want = chainCaveat(want, c)
+	}
+	if !hmac.Equal(sig, want) {
+		return nil, verificationError(ErrInvalidSignature, nil)
+	}
+
+	var ident capabilityIdentifier
+	if err := json.Unmarshal(id, &ident); err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	if ident.Issuer != j.e.JWTIssuer {
+		return nil, verificationError(ErrInvalidIssuer, nil)
+	}
+	if !req.Time.Before(time.Unix(ident.ExpiresAt, 0).Add(j.e.JWTLeeway)) {
+		return nil, verificationError(ErrTokenExpired, nil)
+	}
+
+	for _, raw := range caveats {
+		c, err := parseCaveat(string(raw))
+		if err != nil {
+			return nil, verificationError(ErrCaveatNotSatisfied, err)
+		}
+		if !c.satisfied(req, j.e.JWTLeeway) {
+			return nil, verificationError(
+				ErrCaveatNotSatisfied,
+				fmt.Errorf("%q", raw),
+			)
+		}
+	}
+
+	return &Claims{
+		Claims: jwtclaims.Claims{
+			Role:      ident.Role,
+			SessionID: ident.SessionID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    ident.Issuer,
+				Subject:   ident.Subject,
+				ID:        ident.ID,
+				IssuedAt:  jwt.NewNumericDate(time.Unix(ident.IssuedAt, 0)),
+				ExpiresAt: jwt.NewNumericDate(time.Unix(ident.ExpiresAt, 0)),
+			},
+		},
+		Capability: true,
+	}, nil
+}
+
+// ValidateCaveat reports whether a caveat is well formed and supported.
+func ValidateCaveat(c string) error {
+	_, err := parseCaveat(c)
+	return err
+}
+
+// splitCapability decodes the identifier, caveats and signature of a
+// capability token.
+func splitCapability(token string) ([][]byte, error) {
+	if !IsCapability(token) {
+		return nil, errors.New("not a capability token")
+	}
+	encoded := strings.Split(strings.TrimPrefix(token, capabilityPrefix), ".")
+	if len(encoded) < 2 {
+		return nil, errors.New("capability token is truncated")
+	}
+	parts := make([][]byte, len(encoded))
+	for i, p := range encoded {
+		b, err := b64.DecodeString(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid capability segment: %w", err)
+		}
+		parts[i] = b
+	}
+	if len(parts[len(parts)-1]) != sha256.Size {
+		return nil, errors.New("invalid capability signature size")
+	}
+	return parts, nil
+}
+
+func chainCaveat(sig, caveat []byte) []byte {
+	mac := hmac.New(sha256.New, sig)
+	mac.Write(caveat)
+	return mac.Sum(nil)
+}
+
+// parseCaveat parses a caveat of the form "<key> <op> <value>".
+func parseCaveat(s string) (caveat, error) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) != 3 || fields[2] == "" {
+		return caveat{}, fmt.Errorf("invalid caveat %q", s)
+	}
+	c := caveat{key: fields[0], op: fields[1], value: fields[2]}
+
+	switch {
+	case c.key == caveatMethod && (c.op == "=" || c.op == "in"):
+	case c.key == caveatPath && (c.op == "=" || c.op == "prefix"):
+		if !strings.HasPrefix(c.value, "/") {
+			return caveat{}, fmt.Errorf("invalid caveat %q: path must be absolute", s)
+		}
+	case c.key == caveatTime && (c.op == "<" || c.op == ">"):
+		if _, err := time.Parse(time.RFC3339, c.value); err != nil {
+			return caveat{}, fmt.Errorf("invalid caveat %q: %w", s, err)
+		}
+	default:
+		return caveat{}, fmt.Errorf("unsupported caveat %q", s)
+	}
+	return c, nil
+}
+
+// satisfied evaluates the caveat against a request. Time bounds allow for
+// leeway of clock skew.
+func (c caveat) satisfied(req CapabilityRequest, leeway time.Duration) bool {
+	switch c.key {
+	case caveatMethod:
+		if c.op == "in" {
+			return slices.Contains(strings.Split(c.value, ","), req.Method)
+		}
+		return req.Method == c.value
+
+	case caveatPath:
+		// Match against the cleaned path so "/docs/../admin" cannot
+		// escape a prefix. Prefixes stop at segment boundaries, so
+		// "/docs" does not match "/docsecret".
+		p := path.Clean("/" + req.Path)
+		if c.op == "prefix" {
+			return p == c.value ||
+				strings.HasPrefix(p, strings.TrimSuffix(c.value, "/")+"/")
+		}
+		return p == c.value
+
+	case caveatTime:
+		t, _ := time.Parse(time.RFC3339, c.value)
+		if c.op == "<" {
+			return req.Time.Before(t.Add(leeway))
+		}
+		return req.Time.After(t.Add(-leeway))
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package jwtutil
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+)
+
+// ErrClaimsTooLarge is returned when the custom claims built from a claims
+// template exceed the configured size budget.
+var ErrClaimsTooLarge = errors.New("custom claims exceed size budget")
+
+// Confirmation is the RFC 7800 cnf claim, which binds a token to a key the
+// client must prove possession of.
+type Confirmation = jwtclaims.Confirmation
+
+// CertificateThumbprint is the x5t#S256 value of a certificate.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return b64.EncodeToString(sum[:])
+}
+
+// Actor is the RFC 8693 act claim, naming the party acting on behalf of the
+// token's subject.
+type Actor = jwtclaims.Actor
+
+// TokenSubject is the user an access token is issued to.
+type TokenSubject struct {
+	UserID string
+	Role   string
+	// Confirmation binds the token to the client's DPoP key or TLS
+	// certificate when set.
+	Confirmation Confirmation
+	// Audience overrides the configured audience, and Scope and Actor are
+	// set on tokens obtained through token exchange.
+	Audience []string
+	Scope    string
+	Actor    *Actor
+	// Attributes holds the user-record values the claims template may
+	// reference, keyed by attribute name.
+	Attributes map[string]any
+	// SessionID is the refresh session the token is issued from, if any.
+	SessionID string
+}
+
+// validateClaimsTemplate makes sure the template does not try to override
+// claims set by the application.
+func validateClaimsTemplate(template map[string]string) error {
+	for name := range template {
+		if jwtclaims.IsReserved(name) {
+			return fmt.Errorf("claims template cannot set reserved claim %q", name)
+		}
+	}
+	return nil
+}
+
+// customClaims fills the configured claims template from the subject's
+// attributes. Empty attributes are left out, and the result must fit the
+// configured size budget once serialized.
+func (j *JWTUtil) customClaims(sub TokenSubject) (map[string]any, error) {
+	if len(j.e.JWTClaimsTemplate) == 0 {
+		return nil, nil
+	}
+
+	custom := map[string]any{}
+	for name, attr := range j.e.JWTClaimsTemplate {
+		value, ok := sub.Attributes[attr]
+		if !ok || isEmptyClaim(value) {
+			continue
+		}
+		custom[name] = value
+	}
+	if len(custom) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(custom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid custom claims: %w", err)
+	}
+	if len(b) > j.e.JWTClaimsMaxBytes {
+		return nil, fmt.Errorf(
+			"%w: %d bytes, budget is %d",
+			ErrClaimsTooLarge,
+			len(b),
+			j.e.JWTClaimsMaxBytes,
+		)
+	}
+
+	return custom, nil
+}
+
+func isEmptyClaim(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return false
+}
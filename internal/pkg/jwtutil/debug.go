@@ -0,0 +1,243 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenFormatCapability is reported by Decode for capability tokens.
+const TokenFormatCapability = "capability"
+
+// DecodedToken is a token's header and claims, as read by Decode.
+type DecodedToken struct {
+	Format string         `json:"format"`
+	Header map[string]any `json:"header,omitempty"`
+	Claims map[string]any `json:"claims"`
+}
+
+// VerificationStep is the outcome of one of the checks ParseAndVerify
+// makes. Steps after a failed authentication step are skipped, since claims
+// that cannot be trusted are not worth checking.
+type VerificationStep struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MintSpec describes a token minted by Mint. An empty Issuer or Audience
+// defaults to the configured one, and Claims are added as top-level claims
+// without overriding the registered ones.
+type MintSpec struct {
+	Subject  string
+	Role     string
+	Issuer   string
+	Audience []string
+	// TTL may be negative to mint an already expired token.
+	TTL    time.Duration
+	Claims map[string]any
+}
+
+// Decode reads a token's header and claims without checking them. JWE,
+// PASETO v4.local and opaque tokens still need this server's keys or token
+// store to be read at all. It is meant for debugging only.
+func (j *JWTUtil) Decode(ctx context.Context, tokenStr string) (*DecodedToken, error) {
+	if IsCapability(tokenStr) {
+		return decodeCapability(tokenStr)
+	}
+
+	d := &DecodedToken{Format: j.e.TokenFormat}
+	var claims *Claims
+	switch j.e.TokenFormat {
+	case TokenFormatOpaque:
+		c, err := j.resolveOpaque(ctx, tokenStr)
+		if err != nil {
+			return nil, err
+		}
+		claims = c
+
+	case TokenFormatPASETOLocal:
+		c, err := j.decryptPASETOLocal(tokenStr)
+		if err != nil {
+			return nil, err
+		}
+		claims = c
+		d.Header = map[string]any{"version": "v4", "purpose": "local"}
+
+	case TokenFormatPASETOPublic:
+		body, footer, err := splitPASETO(tokenStr, pasetoPublicHeader)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) < ed25519.SignatureSize {
+			return nil, verificationError(ErrTokenMalformed, nil)
+		}
+		if d.Claims, err = decodeObject(body[:len(body)-ed25519.SignatureSize]); err != nil {
+			return nil, err
+		}
+		d.Header = map[string]any{"version": "v4", "purpose": "public"}
+		if f, err := decodeObject(footer); err == nil {
+			d.Header["footer"] = f
+		}
+		return d, nil
+
+	default:
+		if j.enc != nil {
+			jws, err := j.enc.Decrypt(tokenStr)
+			switch {
+			case err == nil:
+				tokenStr = jws
+			case !errors.Is(err, ErrTokenNotEncrypted):
+				return nil, verificationError(ErrTokenUndecryptable, err)
+			}
+		}
+		mc := jwt.MapClaims{}
+		tok, _, err := jwt.NewParser().ParseUnverified(tokenStr, mc)
+		if err != nil {
+			return nil, classify(err)
+		}
+		d.Header = tok.Header
+		d.Claims = mc
+		return d, nil
+	}
+
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	if d.Claims, err = decodeObject(b); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Explain runs the checks of ParseAndVerify one by one and reports the
+// outcome of each. The claims are returned when every step passed. Like
+// ParseAndVerify, any error is an unreachable opaque token store.
+func (j *JWTUtil) Explain(
+	ctx context.Context,
+	tokenStr string,
+) ([]VerificationStep, *Claims, error) {
+	checks := j.claimChecks()
+	steps := make([]VerificationStep, 0, len(checks)+1)
+
+	var (
+		claims *Claims
+		err    error
+	)
+	if len(tokenStr) > maxTokenBytes {
+		err = verificationError(ErrTokenTooLarge, nil)
+	} else {
+		claims, err = j.authenticate(ctx, tokenStr)
+	}
+	var verr *VerificationError
+	if err != nil && !errors.As(err, &verr) {
+		return nil, nil, err
+	}
+	steps = append(steps, newVerificationStep(j.authenticationStep(), err))
+	if err != nil {
+		for _, c := range checks {
+			steps = append(steps, VerificationStep{Name: c.name, Skipped: true})
+		}
+		return steps, nil, nil
+	}
+
+	valid := true
+	for _, c := range checks {
+		err := c.check(claims)
+		valid = valid && err == nil
+		steps = append(steps, newVerificationStep(c.name, err))
+	}
+	if !valid {
+		return steps, nil, nil
+	}
+	return steps, claims, nil
+}
+
+// Mint signs a token of the configured format with arbitrary claims. It is
+// meant for testing services that consume tokens, and skips the claims
+// template and its size budget.
+func (j *JWTUtil) Mint(ctx context.Context, spec MintSpec) (string, error) {
+	jti, err := generateRandomBase64(16)
+	if err != nil {
+		return "", err
+	}
+
+	issuer := j.e.JWTIssuer
+	if spec.Issuer != "" {
+		issuer = spec.Issuer
+	}
+	audience := j.e.JWTAudience
+	if len(spec.Audience) > 0 {
+		audience = spec.Audience
+	}
+
+	now := time.Now()
+	return j.SignClaims(ctx, Claims{Claims: jwtclaims.Claims{
+		Role:   spec.Role,
+		Custom: spec.Claims,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   spec.Subject,
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(spec.TTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+	}})
+}
+
+// authenticationStep names the step that authenticates a token of the
+// configured format.
+func (j *JWTUtil) authenticationStep() string {
+	switch j.e.TokenFormat {
+	case TokenFormatOpaque:
+		return "lookup"
+	case TokenFormatPASETOLocal:
+		return "decryption"
+	}
+	if j.enc != nil {
+		return "decryption and signature"
+	}
+	return "signature"
+}
+
+func newVerificationStep(name string, err error) VerificationStep {
+	if err != nil {
+		return VerificationStep{Name: name, Error: err.Error()}
+	}
+	return VerificationStep{Name: name, OK: true}
+}
+
+// decodeCapability reads the identifier and caveats of a capability token.
+func decodeCapability(token string) (*DecodedToken, error) {
+	parts, err := splitCapability(token)
+	if err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	claims, err := decodeObject(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	caveats := make([]string, 0, len(parts)-2)
+	for _, c := range parts[1 : len(parts)-1] {
+		caveats = append(caveats, string(c))
+	}
+	claims["caveats"] = caveats
+	return &DecodedToken{Format: TokenFormatCapability, Claims: claims}, nil
+}
+
+func decodeObject(b []byte) (map[string]any, error) {
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	return m, nil
+}
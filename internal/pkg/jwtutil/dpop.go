@@ -0,0 +1,169 @@
+package jwtutil
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const dpopProofType = "dpop+jwt"
+
+// dpopAlgorithms are the asymmetric algorithms accepted for DPoP proofs.
+var dpopAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+type dpopClaims struct {
+	jwt.RegisteredClaims
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	ATH string `json:"ath,omitempty"`
+}
+
+// DPoPProof is a verified RFC 9449 DPoP proof.
+type DPoPProof struct {
+	// JTI identifies the proof, so replays can be detected.
+	JTI      string
+	IssuedAt time.Time
+	// JKT is the RFC 7638 thumbprint of the key that signed the proof.
+	JKT string
+}
+
+// VerifyDPoPProof checks a DPoP proof JWT sent for an HTTP request to
+// method and rawURL. When the proof accompanies an access token its ath
+// claim must match that token. Proofs issued more than DPoPProofMaxAge ago,
+// or in the future beyond JWTLeeway, are rejected.
+//
+// Replay detection is left to the caller, which must remember proof.JTI for
+// at least DPoPProofMaxAge plus twice JWTLeeway.
+func (j *JWTUtil) VerifyDPoPProof(
+	proof, method, rawURL, accessToken string,
+) (*DPoPProof, error) {
+	var jkt string
+	tok, err := jwt.ParseWithClaims(
+		proof,
+		&dpopClaims{},
+		func(t *jwt.Token) (any, error) {
+			if t.Header["typ"] != dpopProofType {
+				return nil, errors.New("unexpected proof type")
+			}
+			jwk, err := proofJWK(t.Header["jwk"])
+			if err != nil {
+				return nil, err
+			}
+			pub, err := jwk.PublicKey()
+			if err != nil {
+				return nil, err
+			}
+			if err := checkKeyFamily(t.Method, pub); err != nil {
+				return nil, err
+			}
+			jkt = jwk.Thumbprint()
+			return pub, nil
+		},
+		jwt.WithValidMethods(dpopAlgorithms),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, verificationError(ErrInvalidDPoPProof, err)
+	}
+	claims, ok := tok.Claims.(*dpopClaims)
+	if !ok || !tok.Valid {
+		return nil, verificationError(ErrInvalidDPoPProof, nil)
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, verificationError(
+			ErrInvalidDPoPProof,
+			errors.New("proof is missing jti or iat"),
+		)
+	}
+	if claims.HTM != method {
+		return nil, verificationError(
+			ErrInvalidDPoPProof,
+			errors.New("htm does not match the request method"),
+		)
+	}
+	if !sameTarget(claims.HTU, rawURL) {
+		return nil, verificationError(
+			ErrInvalidDPoPProof,
+			errors.New("htu does not match the request url"),
+		)
+	}
+
+	now := time.Now()
+	iat := claims.IssuedAt.Time
+	if iat.After(now.Add(j.e.JWTLeeway)) ||
+		iat.Before(now.Add(-j.e.DPoPProofMaxAge-j.e.JWTLeeway)) {
+		return nil, verificationError(
+			ErrInvalidDPoPProof,
+			fmt.Errorf("proof issued at %s is outside the accepted window", iat),
+		)
+	}
+
+	if accessToken != "" && claims.ATH != AccessTokenHash(accessToken) {
+		return nil, verificationError(
+			ErrInvalidDPoPProof,
+			errors.New("ath does not match the access token"),
+		)
+	}
+
+	return &DPoPProof{
+		JTI:      claims.ID,
+		IssuedAt: iat,
+		JKT:      jkt,
+	}, nil
+}
+
+// AccessTokenHash is the ath value of a DPoP proof for an access token.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return b64.EncodeToString(sum[:])
+}
+
+// proofJWK reads the public key from the jwk header of a proof, rejecting
+// headers that carry private key material.
+func proofJWK(header any) (JWK, error) {
+	members, ok := header.(map[string]any)
+	if !ok {
+		return JWK{}, errors.New("proof has no jwk header")
+	}
+	if _, private := members["d"]; private {
+		return JWK{}, errors.New("proof jwk contains a private key")
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return JWK{}, err
+	}
+	var jwk JWK
+	if err := json.Unmarshal(b, &jwk); err != nil {
+		return JWK{}, fmt.Errorf("invalid proof jwk: %w", err)
+	}
+	return jwk, nil
+}
+
+// sameTarget compares an htu claim with the request URL, ignoring the query
+// and fragment as RFC 9449 section 4.3 requires.
+func sameTarget(htu, rawURL string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		a.EscapedPath() == b.EscapedPath()
+}
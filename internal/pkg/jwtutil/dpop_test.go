@@ -0,0 +1,234 @@
+package jwtutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testDPoPMethod = "POST"
+	testDPoPURL    = "https://auth.example.com/refresh"
+	testDPoPToken  = "access-token"
+)
+
+var testDPoPKey = mustECDSAKey()
+
+func mustECDSAKey() *ecdsa.PrivateKey {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// dpopProof is a proof under construction, which the tests edit before
+// signing it.
+type dpopProof struct {
+	header map[string]any
+	claims jwt.MapClaims
+	method jwt.SigningMethod
+	key    any
+}
+
+// newDPoPProof returns a valid proof for testDPoPMethod, testDPoPURL and
+// testDPoPToken.
+func newDPoPProof(t testing.TB) *dpopProof {
+	t.Helper()
+
+	jwk, err := NewJWK(&testDPoPKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dpopProof{
+		header: map[string]any{"typ": dpopProofType, "jwk": jwk},
+		claims: jwt.MapClaims{
+			"jti": "proof-1",
+			"htm": testDPoPMethod,
+			"htu": testDPoPURL,
+			"iat": time.Now().Unix(),
+			"ath": AccessTokenHash(testDPoPToken),
+		},
+		method: jwt.SigningMethodES256,
+		key:    testDPoPKey,
+	}
+}
+
+func (p *dpopProof) sign(t testing.TB) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(p.method, p.claims)
+	for k, v := range p.header {
+		tok.Header[k] = v
+	}
+	s, err := tok.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newDPoPTestJWTUtil(t testing.TB) *JWTUtil {
+	t.Helper()
+
+	j := newTestJWTUtil(t, "HS256")
+	j.e.DPoPProofMaxAge = time.Minute
+	return j
+}
+
+func TestVerifyDPoPProof(t *testing.T) {
+	j := newDPoPTestJWTUtil(t)
+
+	jwk, err := NewJWK(&testDPoPKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		// noToken verifies the proof as sent to a token endpoint, without
+		// an access token.
+		noToken bool
+		edit    func(p *dpopProof)
+		reject  bool
+	}{
+		{name: "valid"},
+		{name: "query and fragment ignored", url: testDPoPURL + "?x=1#y"},
+		{name: "case-insensitive scheme and host", url: "HTTPS://AUTH.example.com/refresh"},
+		{
+			name:    "no access token",
+			noToken: true,
+			edit:    func(p *dpopProof) { delete(p.claims, "ath") },
+		},
+		{
+			name:   "htm mismatch",
+			edit:   func(p *dpopProof) { p.claims["htm"] = "GET" },
+			reject: true,
+		},
+		{
+			name:   "htu path mismatch",
+			edit:   func(p *dpopProof) { p.claims["htu"] = "https://auth.example.com/sign-in" },
+			reject: true,
+		},
+		{
+			name:   "htu host mismatch",
+			edit:   func(p *dpopProof) { p.claims["htu"] = "https://evil.example.com/refresh" },
+			reject: true,
+		},
+		{
+			name:   "htu scheme mismatch",
+			edit:   func(p *dpopProof) { p.claims["htu"] = "http://auth.example.com/refresh" },
+			reject: true,
+		},
+		{
+			name:   "iat too old",
+			edit:   func(p *dpopProof) { p.claims["iat"] = time.Now().Add(-2 * time.Minute).Unix() },
+			reject: true,
+		},
+		{
+			name:   "iat in the future",
+			edit:   func(p *dpopProof) { p.claims["iat"] = time.Now().Add(time.Minute).Unix() },
+			reject: true,
+		},
+		{
+			name:   "iat missing",
+			edit:   func(p *dpopProof) { delete(p.claims, "iat") },
+			reject: true,
+		},
+		{
+			name:   "jti missing",
+			edit:   func(p *dpopProof) { delete(p.claims, "jti") },
+			reject: true,
+		},
+		{
+			name:   "ath mismatch",
+			edit:   func(p *dpopProof) { p.claims["ath"] = AccessTokenHash("another-token") },
+			reject: true,
+		},
+		{
+			name:   "ath missing",
+			edit:   func(p *dpopProof) { delete(p.claims, "ath") },
+			reject: true,
+		},
+		{
+			name:   "wrong typ",
+			edit:   func(p *dpopProof) { p.header["typ"] = "JWT" },
+			reject: true,
+		},
+		{
+			name:   "no jwk",
+			edit:   func(p *dpopProof) { delete(p.header, "jwk") },
+			reject: true,
+		},
+		{
+			name: "jwk with private key",
+			edit: func(p *dpopProof) {
+				p.header["jwk"] = map[string]any{
+					"kty": jwk.Kty,
+					"crv": jwk.Crv,
+					"x":   jwk.X,
+					"y":   jwk.Y,
+					"d":   b64.EncodeToString(testDPoPKey.D.Bytes()),
+				}
+			},
+			reject: true,
+		},
+		{
+			name:   "signed by another key",
+			edit:   func(p *dpopProof) { p.key = mustECDSAKey() },
+			reject: true,
+		},
+		{
+			name: "symmetric algorithm",
+			edit: func(p *dpopProof) {
+				p.method = jwt.SigningMethodHS256
+				p.key = []byte("secret")
+			},
+			reject: true,
+		},
+		{
+			name: "algorithm of another key family",
+			edit: func(p *dpopProof) {
+				p.method = jwt.SigningMethodRS256
+				p.key = testRSAKey
+			},
+			reject: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newDPoPProof(t)
+			if tt.edit != nil {
+				tt.edit(p)
+			}
+			url := tt.url
+			if url == "" {
+				url = testDPoPURL
+			}
+			token := testDPoPToken
+			if tt.noToken {
+				token = ""
+			}
+
+			got, err := j.VerifyDPoPProof(p.sign(t), testDPoPMethod, url, token)
+			if tt.reject {
+				if !errors.Is(err, ErrInvalidDPoPProof) {
+					t.Fatalf("VerifyDPoPProof() error = %v, want ErrInvalidDPoPProof", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyDPoPProof() error = %v", err)
+			}
+			if got.JTI != "proof-1" || got.JKT != jwk.Thumbprint() {
+				t.Fatalf("VerifyDPoPProof() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package jwtutil
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verification failure reasons. Every rejected token makes ParseAndVerify
+// return a *VerificationError whose Reason is one of these, so callers can
+// tell failures apart with errors.Is.
+var (
+	ErrTokenMalformed     = errors.New("malformed token")
+	ErrTokenTooLarge      = errors.New("token too large")
+	ErrTokenNotEncrypted  = errors.New("token is not encrypted")
+	ErrTokenUndecryptable = errors.New("token cannot be decrypted")
+	ErrUnknownToken       = errors.New("unknown token")
+	ErrUnknownKey         = errors.New("unknown signing key")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrMissingClaim       = errors.New("missing required claim")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenNotYetValid   = errors.New("token not valid yet")
+	ErrTokenTooOld        = errors.New("token exceeds maximum age")
+	ErrInvalidIssuer      = errors.New("invalid issuer")
+	ErrInvalidAudience    = errors.New("invalid audience")
+	ErrInvalidDPoPProof   = errors.New("invalid dpop proof")
+	ErrCaveatNotSatisfied = errors.New("caveat not satisfied")
+)
+
+// VerificationError describes why a token was rejected. Reason is one of the
+// sentinel errors above; Err, when set, is the underlying cause.
+type VerificationError struct {
+	Reason error
+	Err    error
+}
+
+func (e *VerificationError) Error() string {
+	if e.Err == nil || e.Err == e.Reason {
+		return e.Reason.Error()
+	}
+	return e.Reason.Error() + ": " + e.Err.Error()
+}
+
+func (e *VerificationError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Reason}
+	}
+	return []error{e.Reason, e.Err}
+}
+
+func verificationError(reason, err error) *VerificationError {
+	return &VerificationError{Reason: reason, Err: err}
+}
+
+// classify maps errors from the golang-jwt parser to a verification reason.
+// The order matters: the parser joins several errors for a single failure
+// (e.g. ErrTokenInvalidClaims with ErrTokenExpired).
+func classify(err error) *VerificationError {
+	var reason error
+	switch {
+	case errors.Is(err, ErrUnknownKey):
+		reason = ErrUnknownKey
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		reason = ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenUnverifiable),
+		errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		reason = ErrInvalidSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		reason = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet),
+		errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		reason = ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		reason = ErrInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		reason = ErrInvalidAudience
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		reason = ErrMissingClaim
+	default:
+		reason = ErrTokenMalformed
+	}
+	return verificationError(reason, err)
+}
@@ -0,0 +1,210 @@
+package jwtutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+)
+
+// Supported JWE key management algorithms. Content is always encrypted with
+// A256GCM.
+const (
+	JWEAlgorithmDirect     = "dir"
+	JWEAlgorithmRSAOAEP256 = "RSA-OAEP-256"
+
+	jweEncryption = "A256GCM"
+)
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// encrypter wraps signed access tokens in a compact JWE (RFC 7516), producing
+// nested signed-then-encrypted tokens so claims cannot be read in transit.
+type encrypter struct {
+	alg string
+	// key is the content encryption key for "dir".
+	key []byte
+	// priv unwraps content encryption keys for "RSA-OAEP-256".
+	priv *rsa.PrivateKey
+}
+
+// newEncrypter returns nil when token encryption is disabled.
+func newEncrypter(e *env.Env) (*encrypter, error) {
+	switch e.JWEAlgorithm {
+	case "":
+		return nil, nil
+
+	case JWEAlgorithmDirect:
+		key, err := base64.StdEncoding.DecodeString(e.JWEKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode jwe key: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, errors.New("jwe key must be 32 bytes for A256GCM")
+		}
+		return &encrypter{alg: e.JWEAlgorithm, key: key}, nil
+
+	case JWEAlgorithmRSAOAEP256:
+		pemBytes, err := os.ReadFile(e.JWEPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwe private key: %w", err)
+		}
+		priv, err := parsePrivateKeyPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwe private key: %w", err)
+		}
+		rsaPriv, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwe private key must be an RSA key")
+		}
+		return &encrypter{alg: e.JWEAlgorithm, priv: rsaPriv}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported jwe algorithm %q", e.JWEAlgorithm)
+}
+
+// Encrypt wraps a signed JWT in a compact JWE.
+func (c *encrypter) Encrypt(jws string) (string, error) {
+	header, err := json.Marshal(jweHeader{
+		Alg: c.alg,
+		Enc: jweEncryption,
+		Cty: "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+	protected := b64.EncodeToString(header)
+
+	cek := c.key
+	var encryptedKey []byte
+	if c.alg == JWEAlgorithmRSAOAEP256 {
+		cek = make([]byte, 32)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		encryptedKey, err = rsa.EncryptOAEP(
+			sha256.New(),
+			rand.Reader,
+			&c.priv.PublicKey,
+			cek,
+			nil,
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(jws), []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		b64.EncodeToString(encryptedKey),
+		b64.EncodeToString(iv),
+		b64.EncodeToString(ciphertext),
+		b64.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt returns the signed JWT nested in a compact JWE. Only the
+// configured key management algorithm is accepted.
+func (c *encrypter) Decrypt(jwe string) (string, error) {
+	parts := strings.Split(jwe, ".")
+	if len(parts) != 5 {
+		return "", ErrTokenNotEncrypted
+	}
+
+	headerBytes, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid jwe header: %w", err)
+	}
+	var header jweHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return "", fmt.Errorf("invalid jwe header: %w", err)
+	}
+	if header.Alg != c.alg || header.Enc != jweEncryption {
+		return "", fmt.Errorf("unexpected jwe algorithm %s/%s", header.Alg, header.Enc)
+	}
+	if header.Cty != "JWT" {
+		return "", errors.New("jwe does not contain a jwt")
+	}
+
+	var segments [4][]byte
+	for i := range segments {
+		if segments[i], err = b64.DecodeString(parts[i+1]); err != nil {
+			return "", fmt.Errorf("invalid jwe segment: %w", err)
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := segments[0], segments[1], segments[2], segments[3]
+
+	cek := c.key
+	switch c.alg {
+	case JWEAlgorithmDirect:
+		if len(encryptedKey) != 0 {
+			return "", errors.New("unexpected encrypted key for dir")
+		}
+	case JWEAlgorithmRSAOAEP256:
+		if cek, err = rsa.DecryptOAEP(
+			sha256.New(),
+			nil,
+			c.priv,
+			encryptedKey,
+			nil,
+		); err != nil {
+			return "", errors.New("failed to unwrap content encryption key")
+		}
+		// A256GCM requires a 256-bit key; anything else would silently
+		// downgrade to AES-128 or AES-192.
+		if len(cek) != 32 {
+			return "", errors.New("invalid content encryption key size")
+		}
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return "", errors.New("invalid jwe iv or tag")
+	}
+	plaintext, err := gcm.Open(
+		nil,
+		iv,
+		append(ciphertext, tag...),
+		[]byte(parts[0]),
+	)
+	if err != nil {
+		return "", errors.New("failed to decrypt token")
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
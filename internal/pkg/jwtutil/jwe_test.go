@@ -0,0 +1,267 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+)
+
+// newTestEncrypter returns an encrypter for alg with a fresh key, or with
+// testRSAKey for RSA-OAEP-256.
+func newTestEncrypter(t testing.TB, alg string) *encrypter {
+	t.Helper()
+
+	e := &env.Env{JWEAlgorithm: alg}
+	switch alg {
+	case JWEAlgorithmDirect:
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+		e.JWEKey = base64.StdEncoding.EncodeToString(key)
+	case JWEAlgorithmRSAOAEP256:
+		der, err := x509.MarshalPKCS8PrivateKey(testRSAKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.JWEPrivateKeyFile = filepath.Join(t.TempDir(), "jwe.pem")
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(e.JWEPrivateKeyFile, keyPEM, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := newEncrypter(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// editSegment returns jwe with segment i passed through edit.
+func editSegment(t testing.TB, jwe string, i int, edit func([]byte) []byte) string {
+	t.Helper()
+
+	parts := strings.Split(jwe, ".")
+	b, err := b64.DecodeString(parts[i])
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[i] = b64.EncodeToString(edit(b))
+	return strings.Join(parts, ".")
+}
+
+// flipLastByte corrupts a segment without changing its length.
+func flipLastByte(b []byte) []byte {
+	b = append([]byte(nil), b...)
+	b[len(b)-1] ^= 1
+	return b
+}
+
+// withHeader replaces the protected header.
+func withHeader(header jweHeader) func([]byte) []byte {
+	return func([]byte) []byte {
+		b, _ := json.Marshal(header)
+		return b
+	}
+}
+
+// decodeJWEHeader returns the protected header of jwe.
+func decodeJWEHeader(t testing.TB, jwe string) jweHeader {
+	t.Helper()
+
+	b, err := b64.DecodeString(strings.SplitN(jwe, ".", 2)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h jweHeader
+	if err := json.Unmarshal(b, &h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+var jweAlgorithms = []string{JWEAlgorithmDirect, JWEAlgorithmRSAOAEP256}
+
+func TestJWERoundTrip(t *testing.T) {
+	const jws = "header.payload.signature"
+
+	for _, alg := range jweAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			c := newTestEncrypter(t, alg)
+
+			first, err := c.Encrypt(jws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := c.Encrypt(jws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == second {
+				t.Fatal("encrypting twice produced the same JWE")
+			}
+			if strings.Contains(first, "payload") {
+				t.Fatalf("JWE leaks the plaintext: %s", first)
+			}
+
+			for _, jwe := range []string{first, second} {
+				got, err := c.Decrypt(jwe)
+				if err != nil {
+					t.Fatalf("Decrypt() error = %v", err)
+				}
+				if got != jws {
+					t.Fatalf("Decrypt() = %q, want %q", got, jws)
+				}
+			}
+		})
+	}
+}
+
+func TestJWEDecryptRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t testing.TB, jwe string) string
+	}{
+		{
+			name: "tampered tag",
+			tamper: func(t testing.TB, jwe string) string {
+				return editSegment(t, jwe, 4, flipLastByte)
+			},
+		},
+		{
+			name: "tampered ciphertext",
+			tamper: func(t testing.TB, jwe string) string {
+				return editSegment(t, jwe, 3, flipLastByte)
+			},
+		},
+		{
+			name: "tampered iv",
+			tamper: func(t testing.TB, jwe string) string {
+				return editSegment(t, jwe, 2, flipLastByte)
+			},
+		},
+		{
+			name: "truncated tag",
+			tamper: func(t testing.TB, jwe string) string {
+				return editSegment(t, jwe, 4, func(b []byte) []byte { return b[:len(b)-1] })
+			},
+		},
+		{
+			name: "wrong enc",
+			tamper: func(t testing.TB, jwe string) string {
+				alg := decodeJWEHeader(t, jwe).Alg
+				return editSegment(t, jwe, 0, withHeader(jweHeader{Alg: alg, Enc: "A128GCM", Cty: "JWT"}))
+			},
+		},
+		{
+			name: "wrong alg",
+			tamper: func(t testing.TB, jwe string) string {
+				alg := JWEAlgorithmDirect
+				if decodeJWEHeader(t, jwe).Alg == alg {
+					alg = JWEAlgorithmRSAOAEP256
+				}
+				return editSegment(t, jwe, 0, withHeader(jweHeader{Alg: alg, Enc: jweEncryption, Cty: "JWT"}))
+			},
+		},
+		{
+			// The protected header is authenticated, so even an edit the
+			// header checks accept fails to decrypt.
+			name: "header edited",
+			tamper: func(t testing.TB, jwe string) string {
+				h := decodeJWEHeader(t, jwe)
+				return editSegment(t, jwe, 0, func([]byte) []byte {
+					b, _ := json.Marshal(map[string]string{"alg": h.Alg, "enc": h.Enc, "cty": h.Cty, "kid": "x"})
+					return b
+				})
+			},
+		},
+		{
+			name: "missing cty",
+			tamper: func(t testing.TB, jwe string) string {
+				alg := decodeJWEHeader(t, jwe).Alg
+				return editSegment(t, jwe, 0, withHeader(jweHeader{Alg: alg, Enc: jweEncryption}))
+			},
+		},
+		{
+			name: "encrypted key substituted",
+			tamper: func(t testing.TB, jwe string) string {
+				return editSegment(t, jwe, 1, func(b []byte) []byte {
+					if len(b) == 0 {
+						return []byte{1}
+					}
+					return flipLastByte(b)
+				})
+			},
+		},
+	}
+	for _, alg := range jweAlgorithms {
+		for _, tt := range tests {
+			t.Run(alg+"/"+tt.name, func(t *testing.T) {
+				c := newTestEncrypter(t, alg)
+				jwe, err := c.Encrypt("header.payload.signature")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, err := c.Decrypt(tt.tamper(t, jwe)); err == nil {
+					t.Fatalf("Decrypt() = %q, want an error", got)
+				}
+			})
+		}
+	}
+}
+
+func TestJWEDecryptWrongKey(t *testing.T) {
+	c := newTestEncrypter(t, JWEAlgorithmDirect)
+	jwe, err := c.Encrypt("header.payload.signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestEncrypter(t, JWEAlgorithmDirect)
+	if _, err := other.Decrypt(jwe); err == nil {
+		t.Fatal("Decrypt() with another key succeeded")
+	}
+}
+
+func TestParseAndVerifyEncrypted(t *testing.T) {
+	ctx := context.Background()
+	j := newTestJWTUtil(t, "HS256")
+	signed := sign(t, j, validClaims(), nil)
+	j.enc = newTestEncrypter(t, JWEAlgorithmDirect)
+
+	encrypted, err := j.enc.Encrypt(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.ParseAndVerify(ctx, encrypted); err != nil {
+		t.Fatalf("ParseAndVerify() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"unencrypted", signed, ErrTokenNotEncrypted},
+		{"tampered tag", editSegment(t, encrypted, 4, flipLastByte), ErrTokenUndecryptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := j.ParseAndVerify(ctx, tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("ParseAndVerify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
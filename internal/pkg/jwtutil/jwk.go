@@ -0,0 +1,21 @@
+package jwtutil
+
+import (
+	"crypto"
+	"encoding/base64"
+
+	"github.com/dyegopenha/jwt-playground/pkg/jwk"
+)
+
+// JWK is the public part of a signing key as described by RFC 7517.
+type JWK = jwk.Key
+
+// JWKSet is a JSON Web Key Set as described by RFC 7517 section 5.
+type JWKSet = jwk.Set
+
+var b64 = base64.RawURLEncoding
+
+// NewJWK builds the JWK representation of a public key.
+func NewJWK(pub crypto.PublicKey) (JWK, error) {
+	return jwk.New(pub)
+}
@@ -1,30 +1,82 @@
 package jwtutil
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// maxTokenBytes bounds the size of tokens that are parsed at all, well
+// above what the claims size budget allows.
+const maxTokenBytes = 8 << 10
+
 type JWTUtil struct {
-	e *env.Env
+	e    *env.Env
+	c    cache.Cache
+	keys *Keyring
+	// enc is nil unless access tokens are encrypted.
+	enc *encrypter
+	// pasetoKey is the v4.local key, nil for other token formats.
+	pasetoKey []byte
+	// capKey is the root key of capability tokens, nil when disabled.
+	capKey []byte
 }
 
-func NewJWTUtil(e *env.Env) *JWTUtil {
+func NewJWTUtil(e *env.Env, c cache.Cache) *JWTUtil {
+	keys, err := loadKeyring(e)
+	if err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+
+	enc, err := newEncrypter(e)
+	if err != nil {
+		log.Fatalf("failed to load encryption key: %v", err)
+	}
+
+	pasetoKey, err := loadPASETOLocalKey(e)
+	if err != nil {
+		log.Fatalf("failed to load paseto key: %v", err)
+	}
+
+	capKey, err := loadCapabilityKey(e)
+	if err != nil {
+		log.Fatalf("failed to load capability key: %v", err)
+	}
+
+	if err := validateClaimsTemplate(e.JWTClaimsTemplate); err != nil {
+		log.Fatalf("invalid claims template: %v", err)
+	}
+
 	return &JWTUtil{
-		e: e,
+		e:         e,
+		c:         c,
+		keys:      keys,
+		enc:       enc,
+		pasetoKey: pasetoKey,
+		capKey:    capKey,
 	}
 }
 
-// Claims represents the JWT payload used across the application.
-// It embeds jwt.RegisteredClaims to take advantage of the built-in
-// validations provided by the golang-jwt library.
+// Keyring returns the keys used to sign and verify access tokens.
+func (j *JWTUtil) Keyring() *Keyring {
+	return j.keys
+}
+
+// Claims represents the token payload used across the application: the
+// access token claims shared with pkg/verifier, and how they were obtained.
 type Claims struct {
-	jwt.RegisteredClaims
-	Role string `json:"role"`
+	jwtclaims.Claims
+	// Capability is set when the claims come from a capability token.
+	Capability bool `json:"-"`
 }
 
 func generateRandomBase64(n int) (string, error) {
@@ -37,41 +89,283 @@ func generateRandomBase64(n int) (string, error) {
 		nil
 }
 
-// SignAccessToken creates and signs a short-lived access token.
+// SignAccessToken creates and signs a short-lived access token for the user,
+// including the custom claims configured by the claims template.
 func (j *JWTUtil) SignAccessToken(
-	userID, role string,
+	ctx context.Context,
+	sub TokenSubject,
 	ttl time.Duration,
 ) (string, error) {
-	claims := Claims{
-		Role: role,
+	jti, err := generateRandomBase64(16)
+	if err != nil {
+		return "", err
+	}
+
+	custom, err := j.customClaims(sub)
+	if err != nil {
+		return "", err
+	}
+
+	audience := j.e.JWTAudience
+	if len(sub.Audience) > 0 {
+		audience = sub.Audience
+	}
+
+	now := time.Now()
+	claims := Claims{Claims: jwtclaims.Claims{
+		Role:      sub.Role,
+		Scope:     sub.Scope,
+		Actor:     sub.Actor,
+		SessionID: sub.SessionID,
+		Custom:    custom,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    userID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    j.e.JWTIssuer,
+			Subject:   sub.UserID,
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
 		},
+	}}
+	if !sub.Confirmation.IsZero() {
+		cnf := sub.Confirmation
+		claims.Confirmation = &cnf
+	}
+	return j.SignClaims(ctx, claims)
+}
+
+// SignClaims turns the claims into a token of the configured format: a JWT
+// or PASETO v4.public token signed with the active key, a PASETO v4.local
+// token, or an opaque handle to claims stored in the cache. When JWE
+// encryption is enabled a signed JWT is then wrapped in a JWE.
+func (j *JWTUtil) SignClaims(ctx context.Context, claims Claims) (string, error) {
+	switch j.e.TokenFormat {
+	case TokenFormatOpaque:
+		return j.storeOpaque(ctx, claims)
+	case TokenFormatPASETOPublic:
+		return j.signPASETOPublic(ctx, claims)
+	case TokenFormatPASETOLocal:
+		return j.encryptPASETOLocal(claims)
+	}
+
+	key := j.keys.Active()
+	tok := jwt.NewWithClaims(key.Method, claims)
+	tok.Header["kid"] = key.ID
+	signed, err := signToken(ctx, tok, key.signer)
+	if err != nil || j.enc == nil {
+		return signed, err
+	}
+	return j.enc.Encrypt(signed)
+}
+
+// ParseAndVerify validates a token of the configured format and returns the
+// Claims inside it. Only tokens issued by the configured issuer and for at
+// least one of the configured audiences are accepted.
+//
+// Time-based checks allow for JWTLeeway of clock skew, and tokens issued
+// more than JWTMaxTokenAge ago are rejected when a maximum age is set.
+// Tokens larger than 8 KiB are rejected before being parsed.
+// Rejected tokens always produce a *VerificationError; any other error means
+// the opaque token store could not be reached.
+func (j *JWTUtil) ParseAndVerify(
+	ctx context.Context,
+	tokenStr string,
+) (*Claims, error) {
+	if len(tokenStr) > maxTokenBytes {
+		return nil, verificationError(ErrTokenTooLarge, nil)
+	}
+	claims, err := j.authenticate(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := j.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authenticate checks that a token of the configured format was issued by
+// this server and returns its claims without validating them.
+func (j *JWTUtil) authenticate(ctx context.Context, tokenStr string) (*Claims, error) {
+	switch j.e.TokenFormat {
+	case TokenFormatOpaque:
+		return j.resolveOpaque(ctx, tokenStr)
+	case TokenFormatPASETOPublic:
+		return j.parsePASETOPublic(tokenStr)
+	case TokenFormatPASETOLocal:
+		return j.decryptPASETOLocal(tokenStr)
 	}
-	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return tok.SignedString([]byte(j.e.HMACKey))
+	return j.parseJWT(tokenStr)
 }
 
-// ParseAndVerify validates the signature and returns the Claims inside a token.
-func (j *JWTUtil) ParseAndVerify(tokenStr string) (*Claims, error) {
+// parseJWT verifies the signature of a JWT and returns its claims without
+// validating them. The verification key is selected by the token's kid
+// header, and only tokens signed with the configured algorithm are
+// accepted. When JWE encryption is enabled the token is decrypted first, and
+// unencrypted tokens are rejected.
+func (j *JWTUtil) parseJWT(tokenStr string) (*Claims, error) {
+	if j.enc != nil {
+		var err error
+		if tokenStr, err = j.enc.Decrypt(tokenStr); err != nil {
+			if errors.Is(err, ErrTokenNotEncrypted) {
+				return nil, verificationError(ErrTokenNotEncrypted, nil)
+			}
+			return nil, verificationError(ErrTokenUndecryptable, err)
+		}
+	}
+
 	tok, err := jwt.ParseWithClaims(
 		tokenStr,
 		&Claims{},
-		func(t *jwt.Token) (any, error) { return []byte(j.e.HMACKey), nil },
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		j.keyFunc,
+		jwt.WithValidMethods([]string{j.keys.Active().Method.Alg()}),
+		jwt.WithoutClaimsValidation(),
 	)
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 	claims, ok := tok.Claims.(*Claims)
 	if !ok || !tok.Valid {
-		return nil, jwt.ErrTokenInvalidClaims
+		return nil, verificationError(ErrTokenMalformed, nil)
 	}
 	return claims, nil
 }
 
+// claimCheck is one of the checks made on the claims of an authenticated
+// token.
+type claimCheck struct {
+	name  string
+	check func(*Claims) error
+}
+
+// claimChecks lists the claim checks in the order validateClaims makes them.
+func (j *JWTUtil) claimChecks() []claimCheck {
+	return []claimCheck{
+		{"expiration", j.checkExpiration},
+		{"issued at", j.checkIssuedAt},
+		{"not before", j.checkNotBefore},
+		{"issuer", j.checkIssuer},
+		{"audience", j.checkAudience},
+		{"max age", j.checkAge},
+	}
+}
+
+// validateClaims checks the registered claims of an authenticated token.
+func (j *JWTUtil) validateClaims(claims *Claims) error {
+	for _, c := range j.claimChecks() {
+		if err := c.check(claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkExpiration requires an exp claim and rejects expired tokens.
+func (j *JWTUtil) checkExpiration(claims *Claims) error {
+	if claims.ExpiresAt == nil {
+		return verificationError(ErrMissingClaim, jwt.ErrTokenRequiredClaimMissing)
+	}
+	if !time.Now().Add(-j.e.JWTLeeway).Before(claims.ExpiresAt.Time) {
+		return verificationError(ErrTokenExpired, nil)
+	}
+	return nil
+}
+
+// checkIssuedAt rejects tokens issued in the future.
+func (j *JWTUtil) checkIssuedAt(claims *Claims) error {
+	if claims.IssuedAt != nil &&
+		time.Now().Add(j.e.JWTLeeway).Before(claims.IssuedAt.Time) {
+		return verificationError(ErrTokenNotYetValid, jwt.ErrTokenUsedBeforeIssued)
+	}
+	return nil
+}
+
+// checkNotBefore rejects tokens used before their nbf claim.
+func (j *JWTUtil) checkNotBefore(claims *Claims) error {
+	if claims.NotBefore != nil &&
+		time.Now().Add(j.e.JWTLeeway).Before(claims.NotBefore.Time) {
+		return verificationError(ErrTokenNotYetValid, nil)
+	}
+	return nil
+}
+
+// checkIssuer rejects tokens from any issuer but the configured one.
+func (j *JWTUtil) checkIssuer(claims *Claims) error {
+	if claims.Issuer != j.e.JWTIssuer {
+		return verificationError(ErrInvalidIssuer, nil)
+	}
+	return nil
+}
+
+// checkAudience rejects tokens for none of the configured audiences.
+func (j *JWTUtil) checkAudience(claims *Claims) error {
+	if !j.acceptsAudience(claims.Audience) {
+		return verificationError(ErrInvalidAudience, nil)
+	}
+	return nil
+}
+
+// checkAge rejects tokens issued longer ago than the configured maximum age.
+func (j *JWTUtil) checkAge(claims *Claims) error {
+	if j.e.JWTMaxTokenAge <= 0 {
+		return nil
+	}
+	if claims.IssuedAt == nil {
+		return verificationError(ErrMissingClaim, jwt.ErrTokenRequiredClaimMissing)
+	}
+	age := time.Since(claims.IssuedAt.Time)
+	if age > j.e.JWTMaxTokenAge+j.e.JWTLeeway {
+		return verificationError(ErrTokenTooOld, nil)
+	}
+	return nil
+}
+
+// acceptsAudience reports whether aud names at least one configured audience.
+func (j *JWTUtil) acceptsAudience(aud jwt.ClaimStrings) bool {
+	for _, want := range j.e.JWTAudience {
+		if slices.Contains(aud, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFunc returns the verification key matching the token's kid, refusing
+// tokens whose signing method belongs to a different algorithm family than
+// the key (e.g. an HS256 token presented to an RS256 deployment). Tokens
+// without a kid are checked against the active key.
+func (j *JWTUtil) keyFunc(t *jwt.Token) (any, error) {
+	key := j.keys.Active()
+	if v, present := t.Header["kid"]; present {
+		kid, ok := v.(string)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if key, ok = j.keys.Lookup(kid); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if err := checkKeyFamily(t.Method, key.verifyKey); err != nil {
+		return nil, err
+	}
+	return key.verifyKey, nil
+}
+
+// JWKS returns the public keys that verify access tokens. HMAC secrets are
+// never published, so the set is empty when tokens are signed with them.
+func (j *JWTUtil) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range j.keys.Keys() {
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
 // GenerateRefreshToken generates a refresh token
 func (j *JWTUtil) GenerateRefreshToken() (string, error) {
 	refreshToken, err := generateRandomBase64(32)
@@ -83,10 +377,11 @@ func (j *JWTUtil) GenerateRefreshToken() (string, error) {
 
 // IssueTokenPair returns an access token and a refresh token.
 func (j *JWTUtil) IssueTokenPair(
-	userID, role string,
+	ctx context.Context,
+	sub TokenSubject,
 	accessTTL, refreshTTL time.Duration,
 ) (string, string, error) {
-	accessTok, err := j.SignAccessToken(userID, role, accessTTL)
+	accessTok, err := j.SignAccessToken(ctx, sub, accessTTL)
 	if err != nil {
 		return "", "", err
 	}
@@ -0,0 +1,107 @@
+package jwtutil
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a single signing or verification key identified by its kid.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// signer is nil for keys that may only verify tokens.
+	signer    Signer
+	verifyKey any
+}
+
+// CanSign reports whether the key can sign new tokens.
+func (k *Key) CanSign() bool {
+	return k.signer != nil
+}
+
+// JWK returns the public form of the key. HMAC secrets have no public form,
+// so ok is false for them.
+func (k *Key) JWK() (jwk JWK, ok bool) {
+	if _, isHMAC := k.Method.(*jwt.SigningMethodHMAC); isHMAC {
+		return JWK{}, false
+	}
+	jwk, err := NewJWK(k.verifyKey)
+	if err != nil {
+		return JWK{}, false
+	}
+	jwk.Kid = k.ID
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+	return jwk, true
+}
+
+// Keyring holds the active signing key together with every key that is
+// still accepted for verification, so that signing keys can be rotated
+// without invalidating tokens issued with the previous ones.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: map[string]*Key{},
+	}
+}
+
+// Active returns the key new tokens are signed with.
+func (kr *Keyring) Active() *Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active
+}
+
+// Lookup returns the verification key with the given kid.
+func (kr *Keyring) Lookup(kid string) (*Key, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	k, ok := kr.keys[kid]
+	return k, ok
+}
+
+// Add registers a key that is accepted for verification only.
+func (kr *Keyring) Add(k *Key) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[k.ID] = k
+}
+
+// SetActive registers a key and makes it the signing key. The previously
+// active key stays in the ring for verification.
+func (kr *Keyring) SetActive(k *Key) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[k.ID] = k
+	kr.active = k
+}
+
+// Remove drops a key from the ring. The active key cannot be removed.
+func (kr *Keyring) Remove(kid string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.active != nil && kr.active.ID == kid {
+		return
+	}
+	delete(kr.keys, kid)
+}
+
+// Keys returns every key in the ring ordered by kid.
+func (kr *Keyring) Keys() []*Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	keys := make([]*Key, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool { return keys[a].ID < keys[b].ID })
+	return keys
+}
@@ -0,0 +1,366 @@
+package jwtutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loadKeyring builds the keyring from the environment: the configured key
+// becomes the active signing key and every JWT_VERIFICATION_KEYS entry is
+// added as a verification-only key.
+func loadKeyring(e *env.Env) (*Keyring, error) {
+	method := jwt.GetSigningMethod(e.JWTAlgorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", e.JWTAlgorithm)
+	}
+
+	active, err := loadSigningKey(e, method)
+	if err != nil {
+		return nil, err
+	}
+
+	kr := NewKeyring()
+	for kid, value := range e.JWTVerificationKeys {
+		if kid == active.ID {
+			return nil, fmt.Errorf("verification key %q reuses the active kid", kid)
+		}
+		k, err := loadVerificationKey(e, method, kid, value)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %q: %w", kid, err)
+		}
+		kr.Add(k)
+	}
+	kr.SetActive(active)
+
+	return kr, nil
+}
+
+func loadSigningKey(e *env.Env, method jwt.SigningMethod) (*Key, error) {
+	if e.IsHMAC() {
+		k := newHMACKey(method, []byte(e.HMACKey))
+		if e.JWTKeyID != "" {
+			k.ID = e.JWTKeyID
+		}
+		return k, nil
+	}
+
+	if e.JWTSignerURL != "" {
+		return loadRemoteSigningKey(e, method)
+	}
+
+	pemBytes := []byte(e.JWTPrivateKey)
+	if e.JWTPrivateKeyFile != "" {
+		var err error
+		pemBytes, err = os.ReadFile(e.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+
+	priv, err := parsePrivateKeyPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return newAsymmetricKey(e.JWTKeyID, method, priv, priv.Public())
+}
+
+// loadRemoteSigningKey pairs the external key service with the public key
+// that verifies its signatures.
+func loadRemoteSigningKey(e *env.Env, method jwt.SigningMethod) (*Key, error) {
+	pemBytes, err := os.ReadFile(e.JWTSignerPublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signer public key: %w", err)
+	}
+	pub, err := parsePublicKeyPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signer public key: %w", err)
+	}
+
+	k, err := newAsymmetricKey(e.JWTKeyID, method, nil, pub)
+	if err != nil {
+		return nil, err
+	}
+	if k.signer, err = NewRemoteSigner(e.JWTSignerURL, k.ID, method.Alg()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// loadVerificationKey loads a previous key. For HMAC algorithms value is the
+// secret itself; otherwise it is the path to a PEM public or private key.
+func loadVerificationKey(
+	e *env.Env,
+	method jwt.SigningMethod,
+	kid, value string,
+) (*Key, error) {
+	if e.IsHMAC() {
+		secret := []byte(value)
+		return &Key{
+			ID:        kid,
+			Method:    method,
+			verifyKey: secret,
+		}, nil
+	}
+
+	pemBytes, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	pub, err := parsePublicKeyPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	return newAsymmetricKey(kid, method, nil, pub)
+}
+
+func newAsymmetricKey(
+	kid string,
+	method jwt.SigningMethod,
+	priv crypto.Signer,
+	pub crypto.PublicKey,
+) (*Key, error) {
+	if err := checkKeyFamily(method, pub); err != nil {
+		return nil, err
+	}
+
+	if kid == "" {
+		jwk, err := NewJWK(pub)
+		if err != nil {
+			return nil, err
+		}
+		kid = jwk.Thumbprint()
+	}
+
+	k := &Key{
+		ID:        kid,
+		Method:    method,
+		verifyKey: pub,
+	}
+	if priv != nil {
+		k.signer = NewLocalSigner(method, priv)
+	}
+	return k, nil
+}
+
+// hmacKeyID derives a stable kid from an HMAC secret. The secret is run
+// through HMAC so the kid reveals nothing about it.
+func hmacKeyID(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("kid"))
+	return b64.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// parsePrivateKeyPEM parses a PKCS#1, SEC 1 or PKCS#8 encoded private key.
+func parsePrivateKeyPEM(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+// parsePublicKeyPEM parses a PKIX or PKCS#1 public key. A private key is also
+// accepted, in which case its public half is returned.
+func parsePublicKeyPEM(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	priv, err := parsePrivateKeyPEM(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return priv.Public(), nil
+}
+
+// checkKeyFamily makes sure a key can be used with the given signing method,
+// so a misconfigured key is rejected at startup instead of at signing time.
+func checkKeyFamily(method jwt.SigningMethod, pub crypto.PublicKey) error {
+	ok := false
+	switch m := method.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok = pub.([]byte)
+	case *jwt.SigningMethodRSAPSS, *jwt.SigningMethodRSA:
+		_, ok = pub.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		var k *ecdsa.PublicKey
+		if k, ok = pub.(*ecdsa.PublicKey); ok {
+			ok = k.Curve.Params().BitSize == m.CurveBits
+		}
+	case *jwt.SigningMethodEd25519:
+		_, ok = pub.(ed25519.PublicKey)
+	}
+	if !ok {
+		return fmt.Errorf(
+			"key of type %T cannot be used with %s",
+			pub,
+			method.Alg(),
+		)
+	}
+	return nil
+}
+
+// GenerateKey creates a new signing key for the given method.
+func GenerateKey(method jwt.SigningMethod) (*Key, error) {
+	switch m := method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := make([]byte, m.Hash.Size())
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return newHMACKey(method, secret), nil
+	}
+
+	var (
+		priv crypto.Signer
+		err  error
+	)
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSAPSS:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaKeyBits(m.Hash))
+	case *jwt.SigningMethodRSA:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaKeyBits(m.Hash))
+	case *jwt.SigningMethodECDSA:
+		priv, err = ecdsa.GenerateKey(ecdsaCurve(m.CurveBits), rand.Reader)
+	case *jwt.SigningMethodEd25519:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("cannot generate keys for %s", method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsymmetricKey("", method, priv, priv.Public())
+}
+
+// GenerateEncodedKey creates a new signing key together with its encoding
+// for the environment: a printable secret for HMAC_KEY, or a PKCS#8 PEM
+// block for JWT_PRIVATE_KEY. The key's kid is the one the server derives
+// when JWT_KEY_ID is not set.
+func GenerateEncodedKey(method jwt.SigningMethod) (*Key, string, error) {
+	if m, ok := method.(*jwt.SigningMethodHMAC); ok {
+		secret, err := generateRandomBase64(m.Hash.Size())
+		if err != nil {
+			return nil, "", err
+		}
+		return newHMACKey(method, []byte(secret)), secret, nil
+	}
+
+	k, err := GenerateKey(method)
+	if err != nil {
+		return nil, "", err
+	}
+	der, err := marshalPrivateKey(k)
+	if err != nil {
+		return nil, "", err
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return k, string(block), nil
+}
+
+func newHMACKey(method jwt.SigningMethod, secret []byte) *Key {
+	return &Key{
+		ID:        hmacKeyID(secret),
+		Method:    method,
+		signer:    NewLocalSigner(method, secret),
+		verifyKey: secret,
+	}
+}
+
+func rsaKeyBits(h crypto.Hash) int {
+	switch h {
+	case crypto.SHA384:
+		return 3072
+	case crypto.SHA512:
+		return 4096
+	}
+	return 2048
+}
+
+func ecdsaCurve(bits int) elliptic.Curve {
+	switch bits {
+	case 384:
+		return elliptic.P384()
+	case 521:
+		return elliptic.P521()
+	}
+	return elliptic.P256()
+}
+
+// marshalPrivateKey encodes the private part of a key so it can be persisted:
+// the raw secret for HMAC keys and PKCS#8 DER otherwise. Only keys held in
+// process memory can be marshaled.
+func marshalPrivateKey(k *Key) ([]byte, error) {
+	local, ok := k.signer.(*LocalSigner)
+	if !ok {
+		return nil, errors.New("key material is not held locally")
+	}
+	if secret, ok := local.key.([]byte); ok {
+		return secret, nil
+	}
+	return x509.MarshalPKCS8PrivateKey(local.key)
+}
+
+// unmarshalPrivateKey is the inverse of marshalPrivateKey.
+func unmarshalPrivateKey(
+	kid string,
+	method jwt.SigningMethod,
+	der []byte,
+) (*Key, error) {
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		k := newHMACKey(method, der)
+		k.ID = kid
+		return k, nil
+	}
+
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return newAsymmetricKey(kid, method, signer, signer.Public())
+}
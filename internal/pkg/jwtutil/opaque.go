@@ -0,0 +1,55 @@
+package jwtutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TokenFormatOpaque issues random reference handles instead of
+// self-contained tokens. The claims stay server side in the cache.
+const TokenFormatOpaque = "opaque"
+
+// opaqueHandleBytes is the entropy of an opaque token handle.
+const opaqueHandleBytes = 32
+
+func opaqueTokenKey(handle string) string {
+	return "opaque:" + handle
+}
+
+// storeOpaque saves the claims under a new random handle until the token
+// expires and returns the handle.
+func (j *JWTUtil) storeOpaque(ctx context.Context, claims Claims) (string, error) {
+	if claims.ExpiresAt == nil {
+		return "", fmt.Errorf("opaque tokens require an expiration")
+	}
+
+	handle, err := generateRandomBase64(opaqueHandleBytes)
+	if err != nil {
+		return "", err
+	}
+
+	// Keep the claims for as long as the leeway could still accept them, so
+	// an expired token is reported as expired rather than unknown.
+	ttl := time.Until(claims.ExpiresAt.Time) + j.e.JWTLeeway
+	if err := j.c.Set(ctx, opaqueTokenKey(handle), claims, ttl); err != nil {
+		return "", fmt.Errorf("failed to store opaque token: %w", err)
+	}
+
+	return handle, nil
+}
+
+// resolveOpaque returns the claims stored for a handle. Handles that were
+// never issued, or whose claims have been evicted, are rejected with
+// ErrUnknownToken.
+func (j *JWTUtil) resolveOpaque(ctx context.Context, handle string) (*Claims, error) {
+	claims := &Claims{}
+	ok, err := j.c.Scan(ctx, opaqueTokenKey(handle), claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve opaque token: %w", err)
+	}
+	if !ok {
+		return nil, verificationError(ErrUnknownToken, nil)
+	}
+	return claims, nil
+}
@@ -0,0 +1,330 @@
+package jwtutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+// Supported token formats.
+const (
+	TokenFormatJWT          = "jwt"
+	TokenFormatPASETOPublic = "paseto-v4-public"
+	TokenFormatPASETOLocal  = "paseto-v4-local"
+)
+
+const (
+	pasetoPublicHeader       = "v4.public."
+	pasetoLocalHeader        = "v4.local."
+	pasetoNonceSize          = 32
+	pasetoMACSize            = 32
+	pasetoEncryptionKeyInfo  = "paseto-encryption-key"
+	pasetoAuthenticationInfo = "paseto-auth-key-for-aead"
+)
+
+// pasetoFooter is the unencrypted footer of v4.public tokens, which names the
+// key that signed them.
+type pasetoFooter struct {
+	Kid string `json:"kid"`
+}
+
+// loadPASETOLocalKey returns the v4.local symmetric key, or nil when
+// v4.local is not the configured format.
+func loadPASETOLocalKey(e *env.Env) ([]byte, error) {
+	if e.TokenFormat != TokenFormatPASETOLocal {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(e.PASETOLocalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode paseto local key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("paseto local key must be 32 bytes")
+	}
+	return key, nil
+}
+
+// pae is the PASETO pre-authentication encoding.
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	le64 := func(n int) {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&^(1<<63))
+		buf.Write(b[:])
+	}
+	le64(len(pieces))
+	for _, p := range pieces {
+		le64(len(p))
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+// signPASETOPublic produces a v4.public token signed with the active key,
+// which must be an Ed25519 key.
+func (j *JWTUtil) signPASETOPublic(ctx context.Context, claims Claims) (string, error) {
+	key := j.keys.Active()
+	if _, ok := key.Method.(*jwt.SigningMethodEd25519); !ok {
+		return "", errors.New("paseto v4.public requires an EdDSA key")
+	}
+
+	payload, err := marshalPASETOClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	footer, err := json.Marshal(pasetoFooter{Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	return sealPASETOPublic(ctx, key.signer, payload, footer)
+}
+
+// sealPASETOPublic signs payload and footer with s, which must produce
+// Ed25519 signatures. An empty footer is omitted from the token.
+func sealPASETOPublic(ctx context.Context, s Signer, payload, footer []byte) (string, error) {
+	sig, err := s.Sign(
+		ctx,
+		pae([]byte(pasetoPublicHeader), payload, footer, nil),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	token := pasetoPublicHeader + b64.EncodeToString(append(payload, sig...))
+	if len(footer) > 0 {
+		token += "." + b64.EncodeToString(footer)
+	}
+	return token, nil
+}
+
+// parsePASETOPublic verifies a v4.public token and returns its claims.
+func (j *JWTUtil) parsePASETOPublic(token string) (*Claims, error) {
+	body, footer, err := splitPASETO(token, pasetoPublicHeader)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < ed25519.SignatureSize {
+		return nil, verificationError(ErrTokenMalformed, nil)
+	}
+	payload := body[:len(body)-ed25519.SignatureSize]
+	sig := body[len(body)-ed25519.SignatureSize:]
+
+	var f pasetoFooter
+	if err := json.Unmarshal(footer, &f); err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	key, ok := j.keys.Lookup(f.Kid)
+	if !ok {
+		return nil, verificationError(ErrUnknownKey, nil)
+	}
+	pub, ok := key.verifyKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, verificationError(ErrUnknownKey, nil)
+	}
+
+	if !ed25519.Verify(
+		pub,
+		pae([]byte(pasetoPublicHeader), payload, footer, nil),
+		sig,
+	) {
+		return nil, verificationError(ErrInvalidSignature, nil)
+	}
+
+	return unmarshalPASETOClaims(payload)
+}
+
+// encryptPASETOLocal produces a v4.local token.
+func (j *JWTUtil) encryptPASETOLocal(claims Claims) (string, error) {
+	payload, err := marshalPASETOClaims(claims)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, pasetoNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return sealPASETOLocal(j.pasetoKey, nonce, payload, nil)
+}
+
+// sealPASETOLocal encrypts payload under key with the given nonce, which
+// must never be reused. An empty footer is omitted from the token.
+func sealPASETOLocal(key, nonce, payload, footer []byte) (string, error) {
+	encKey, counterNonce, authKey, err := pasetoLocalKeys(key, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := make([]byte, len(payload))
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return "", err
+	}
+	stream.XORKeyStream(ciphertext, payload)
+
+	mac := pasetoLocalMAC(authKey, nonce, ciphertext, footer)
+
+	body := append(append(nonce, ciphertext...), mac...)
+	token := pasetoLocalHeader + b64.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + b64.EncodeToString(footer)
+	}
+	return token, nil
+}
+
+// decryptPASETOLocal authenticates and decrypts a v4.local token.
+func (j *JWTUtil) decryptPASETOLocal(token string) (*Claims, error) {
+	payload, err := openPASETOLocal(j.pasetoKey, token)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalPASETOClaims(payload)
+}
+
+// openPASETOLocal authenticates a v4.local token under key and returns its
+// decrypted payload.
+func openPASETOLocal(key []byte, token string) ([]byte, error) {
+	body, footer, err := splitPASETO(token, pasetoLocalHeader)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < pasetoNonceSize+pasetoMACSize {
+		return nil, verificationError(ErrTokenMalformed, nil)
+	}
+	nonce := body[:pasetoNonceSize]
+	ciphertext := body[pasetoNonceSize : len(body)-pasetoMACSize]
+	mac := body[len(body)-pasetoMACSize:]
+
+	encKey, counterNonce, authKey, err := pasetoLocalKeys(key, nonce)
+	if err != nil {
+		return nil, verificationError(ErrTokenUndecryptable, err)
+	}
+	if !hmac.Equal(mac, pasetoLocalMAC(authKey, nonce, ciphertext, footer)) {
+		return nil, verificationError(ErrInvalidSignature, nil)
+	}
+
+	payload := make([]byte, len(ciphertext))
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return nil, verificationError(ErrTokenUndecryptable, err)
+	}
+	stream.XORKeyStream(payload, ciphertext)
+
+	return payload, nil
+}
+
+// pasetoLocalKeys derives the encryption key, XChaCha20 nonce and
+// authentication key for a v4.local token.
+func pasetoLocalKeys(key, nonce []byte) (encKey, counterNonce, authKey []byte, err error) {
+	h, err := blake2b.New(56, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	h.Write([]byte(pasetoEncryptionKeyInfo))
+	h.Write(nonce)
+	tmp := h.Sum(nil)
+
+	a, err := blake2b.New(32, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	a.Write([]byte(pasetoAuthenticationInfo))
+	a.Write(nonce)
+
+	return tmp[:32], tmp[32:], a.Sum(nil), nil
+}
+
+func pasetoLocalMAC(authKey, nonce, ciphertext, footer []byte) []byte {
+	h, _ := blake2b.New(pasetoMACSize, authKey)
+	h.Write(pae([]byte(pasetoLocalHeader), nonce, ciphertext, footer, nil))
+	return h.Sum(nil)
+}
+
+// splitPASETO checks the header and decodes the body and optional footer.
+func splitPASETO(token, header string) (body, footer []byte, err error) {
+	if !strings.HasPrefix(token, header) {
+		return nil, nil, verificationError(ErrTokenMalformed, nil)
+	}
+	parts := strings.Split(strings.TrimPrefix(token, header), ".")
+	if len(parts) > 2 {
+		return nil, nil, verificationError(ErrTokenMalformed, nil)
+	}
+	if body, err = b64.DecodeString(parts[0]); err != nil {
+		return nil, nil, verificationError(ErrTokenMalformed, err)
+	}
+	if len(parts) == 2 {
+		if footer, err = b64.DecodeString(parts[1]); err != nil {
+			return nil, nil, verificationError(ErrTokenMalformed, err)
+		}
+	}
+	return body, footer, nil
+}
+
+// pasetoTimeClaims are the registered claims PASETO encodes as RFC 3339
+// strings rather than NumericDates.
+var pasetoTimeClaims = []string{"exp", "nbf", "iat"}
+
+func marshalPASETOClaims(claims Claims) ([]byte, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for _, name := range pasetoTimeClaims {
+		if v, ok := m[name].(float64); ok {
+			m[name] = time.Unix(int64(v), 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return json.Marshal(m)
+}
+
+func unmarshalPASETOClaims(payload []byte) (*Claims, error) {
+	m := map[string]any{}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	for _, name := range pasetoTimeClaims {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, verificationError(ErrTokenMalformed, nil)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, verificationError(ErrTokenMalformed, err)
+		}
+		m[name] = t.Unix()
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(b, claims); err != nil {
+		return nil, verificationError(ErrTokenMalformed, err)
+	}
+	return claims, nil
+}
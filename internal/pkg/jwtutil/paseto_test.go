@@ -0,0 +1,226 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The vectors below are from the PASETO v4 test vectors
+// (https://github.com/paseto-standard/test-vectors/blob/master/v4.json).
+// Only those without an implicit assertion apply, since tokens issued here
+// never carry one.
+const (
+	pasetoVectorLocalKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+	pasetoVectorSecretKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	pasetoVectorPublicKey = "1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	pasetoVectorKeyID     = "zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"
+	pasetoVectorFooter    = `{"kid":"` + pasetoVectorKeyID + `"}`
+)
+
+var pasetoLocalVectors = []struct {
+	name    string
+	nonce   string
+	payload string
+	footer  string
+	token   string
+}{
+	{
+		name:    "4-E-1",
+		nonce:   "0000000000000000000000000000000000000000000000000000000000000000",
+		payload: `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		token: "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kA" +
+			"peaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+	},
+	{
+		name:    "4-E-2",
+		nonce:   "0000000000000000000000000000000000000000000000000000000000000000",
+		payload: `{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+		token: "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvS2csCgglvpk5HC0e8kA" +
+			"peaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XIemu9chy3WVKvRBfg6t8wwYHK0ArLxxfZP73W_vfwt5A",
+	},
+	{
+		name:    "4-E-3",
+		nonce:   "df654812bac492663825520ba2f6e67cf5ca5bdc13d4e7507a98cc4c2fcc3ad8",
+		payload: `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+		token: "v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJ" +
+			"PXjpzm0KW9ojM5f4O2mRvE2IcweP-PRdoHjd5-RHCiExR1IK6t6-tyebyWG6Ov7kKvBdkrrAJ837lKP3iDag2hzUPHuMKA",
+	},
+}
+
+var pasetoPublicVectors = []struct {
+	name    string
+	payload string
+	footer  string
+	token   string
+}{
+	{
+		name:    "4-S-1",
+		payload: `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`,
+		token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDow" +
+			"MCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+	},
+	{
+		name:    "4-S-2",
+		payload: `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`,
+		footer:  pasetoVectorFooter,
+		token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDow" +
+			"MCJ9v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw" +
+			".eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+	},
+}
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPASETOLocalVectors(t *testing.T) {
+	key := mustHex(t, pasetoVectorLocalKey)
+
+	for _, v := range pasetoLocalVectors {
+		t.Run(v.name, func(t *testing.T) {
+			token, err := sealPASETOLocal(key, mustHex(t, v.nonce), []byte(v.payload), []byte(v.footer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != v.token {
+				t.Fatalf("sealPASETOLocal() = %s\nwant %s", token, v.token)
+			}
+
+			payload, err := openPASETOLocal(key, v.token)
+			if err != nil {
+				t.Fatalf("openPASETOLocal() error = %v", err)
+			}
+			if string(payload) != v.payload {
+				t.Fatalf("openPASETOLocal() = %s, want %s", payload, v.payload)
+			}
+		})
+	}
+}
+
+func TestPASETOPublicVectors(t *testing.T) {
+	ctx := context.Background()
+	signer := NewLocalSigner(jwt.SigningMethodEdDSA, ed25519.PrivateKey(mustHex(t, pasetoVectorSecretKey)))
+
+	for _, v := range pasetoPublicVectors {
+		t.Run(v.name, func(t *testing.T) {
+			token, err := sealPASETOPublic(ctx, signer, []byte(v.payload), []byte(v.footer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != v.token {
+				t.Fatalf("sealPASETOPublic() = %s\nwant %s", token, v.token)
+			}
+		})
+	}
+}
+
+// newPASETOVectorJWTUtil returns a JWTUtil holding the keys of the test
+// vectors, with the public key registered under the footer's kid.
+func newPASETOVectorJWTUtil(t testing.TB) *JWTUtil {
+	t.Helper()
+
+	j := newTestJWTUtil(t, "HS256")
+	j.pasetoKey = mustHex(t, pasetoVectorLocalKey)
+
+	pub := ed25519.PublicKey(mustHex(t, pasetoVectorPublicKey))
+	k, err := newAsymmetricKey(pasetoVectorKeyID, jwt.SigningMethodEdDSA, nil, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.keys.Add(k)
+	return j
+}
+
+func TestParsePASETOVectors(t *testing.T) {
+	j := newPASETOVectorJWTUtil(t)
+	wantExp := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		parse func(string) (*Claims, error)
+		token string
+		data  string
+	}{
+		{"4-E-1", j.decryptPASETOLocal, pasetoLocalVectors[0].token, "this is a secret message"},
+		{"4-E-2", j.decryptPASETOLocal, pasetoLocalVectors[1].token, "this is a hidden message"},
+		{"4-E-3", j.decryptPASETOLocal, pasetoLocalVectors[2].token, "this is a secret message"},
+		{"4-S-2", j.parsePASETOPublic, pasetoPublicVectors[1].token, "this is a signed message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.parse(tt.token)
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if !claims.ExpiresAt.Time.Equal(wantExp) {
+				t.Fatalf("exp = %s, want %s", claims.ExpiresAt.Time, wantExp)
+			}
+			if claims.Custom["data"] != tt.data {
+				t.Fatalf("data = %v, want %q", claims.Custom["data"], tt.data)
+			}
+		})
+	}
+}
+
+func TestParsePASETORejects(t *testing.T) {
+	j := newPASETOVectorJWTUtil(t)
+	local := pasetoLocalVectors[2].token
+	public := pasetoPublicVectors[1].token
+
+	// flip changes the base64url character at i, counted from the end of
+	// the body when negative.
+	flip := func(token, header string, i int) string {
+		b := []byte(token)
+		if i < 0 {
+			i += len(b)
+		} else {
+			i += len(header)
+		}
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		return string(b)
+	}
+	publicBody := public[:len(public)-len(b64.EncodeToString([]byte(pasetoVectorFooter)))-1]
+
+	tests := []struct {
+		name  string
+		parse func(string) (*Claims, error)
+		token string
+		want  error
+	}{
+		{"local tampered nonce", j.decryptPASETOLocal, flip(local, pasetoLocalHeader, 0), ErrInvalidSignature},
+		{"local tampered ciphertext", j.decryptPASETOLocal, flip(local, pasetoLocalHeader, 50), ErrInvalidSignature},
+		{"local tampered tag", j.decryptPASETOLocal, flip(local, pasetoLocalHeader, -2), ErrInvalidSignature},
+		{"local added footer", j.decryptPASETOLocal, local + "." + b64.EncodeToString([]byte(pasetoVectorFooter)), ErrInvalidSignature},
+		{"local truncated", j.decryptPASETOLocal, local[:len(pasetoLocalHeader)+40], ErrTokenMalformed},
+		{"local as public", j.parsePASETOPublic, local, ErrTokenMalformed},
+		{"public tampered payload", j.parsePASETOPublic, flip(public, pasetoPublicHeader, 10), ErrInvalidSignature},
+		{"public tampered signature", j.parsePASETOPublic, flip(publicBody, pasetoPublicHeader, -2) + public[len(publicBody):], ErrInvalidSignature},
+		{"public without footer", j.parsePASETOPublic, pasetoPublicVectors[0].token, ErrTokenMalformed},
+		{"public unknown kid", j.parsePASETOPublic, publicBody + "." + b64.EncodeToString([]byte(`{"kid":"other"}`)), ErrUnknownKey},
+		{"public as local", j.decryptPASETOLocal, public, ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.parse(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("parse error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
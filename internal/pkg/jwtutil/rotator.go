@@ -0,0 +1,309 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyringCacheKey     = "jwt:keyring"
+	keyringLockCacheKey = "jwt:keyring:lock"
+	keyringLockTTL      = 30 * time.Second
+)
+
+// storedKey is the persisted form of a generated signing key.
+type storedKey struct {
+	ID         string    `json:"kid"`
+	Alg        string    `json:"alg"`
+	PrivateKey []byte    `json:"private_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	// ActivateAt delays signing with a new key until every replica has had
+	// a chance to load it for verification.
+	ActivateAt time.Time `json:"activate_at"`
+	// RetireAt is set once a newer key takes over; the key is dropped when
+	// no token it signed can still be valid.
+	RetireAt time.Time `json:"retire_at,omitzero"`
+}
+
+type storedKeyring struct {
+	Keys []storedKey `json:"keys"`
+	// Retired maps the kids of keys configured through the environment to
+	// the time they stop verifying, once a generated key has replaced them.
+	Retired map[string]time.Time `json:"retired,omitempty"`
+}
+
+// KeyRotator periodically generates new signing keys and shares them with
+// every replica through the cache. Rotation is guarded by a cache lock so
+// only one replica rotates at a time, while every replica reloads the shared
+// keyring on each sync.
+//
+// Generated private keys are persisted in the cache, which must therefore be
+// protected like any other secret store.
+type KeyRotator struct {
+	e  *env.Env
+	c  cache.Cache
+	j  *JWTUtil
+	id string
+
+	// mu serializes Tick and Rotate, which both read and update managed.
+	mu sync.Mutex
+	// managed holds the kids loaded from the cache, as opposed to the ones
+	// configured through the environment.
+	managed map[string]bool
+}
+
+func NewKeyRotator(
+	e *env.Env,
+	c cache.Cache,
+	j *JWTUtil,
+) *KeyRotator {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		log.Fatalf("failed to generate key rotator id: %v", err)
+	}
+
+	return &KeyRotator{
+		e:       e,
+		c:       c,
+		j:       j,
+		id:      hex.EncodeToString(id),
+		managed: map[string]bool{},
+	}
+}
+
+// Enabled reports whether automatic rotation is configured.
+func (r *KeyRotator) Enabled() bool {
+	return r.e.JWTKeyRotationInterval > 0
+}
+
+// Run rotates and syncs keys every sync interval until ctx is canceled.
+// It does not tick right away, so callers run Tick first to load the shared
+// keyring before they start serving.
+func (r *KeyRotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.e.JWTKeySyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Tick(ctx); err != nil {
+			log.Printf("key rotation: %v", err)
+		}
+	}
+}
+
+// Tick rotates the shared keyring if the active key is due and then loads
+// it into the local keyring.
+func (r *KeyRotator) Tick(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if r.due(stored, time.Now()) {
+		if stored, err = r.rotate(ctx, false); err != nil {
+			return err
+		}
+	}
+
+	return r.apply(stored, time.Now())
+}
+
+// Rotate adds a new signing key to the shared keyring now, regardless of
+// the rotation interval, and returns its kid. Replicas start signing with it
+// once it activates, after two sync intervals.
+func (r *KeyRotator) Rotate(ctx context.Context) (string, error) {
+	if !r.Enabled() {
+		return "", errors.New("key rotation is not enabled")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.rotate(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	if len(stored.Keys) == 0 {
+		return "", errors.New("keyring is empty")
+	}
+	return stored.Keys[len(stored.Keys)-1].ID, nil
+}
+
+func (r *KeyRotator) load(ctx context.Context) (storedKeyring, error) {
+	stored := storedKeyring{}
+	if _, err := r.c.Scan(ctx, keyringCacheKey, &stored); err != nil {
+		return storedKeyring{}, fmt.Errorf("failed to load keyring: %w", err)
+	}
+	return stored, nil
+}
+
+// due reports whether the newest stored key is older than the rotation
+// interval. Keys are stored oldest first.
+func (r *KeyRotator) due(stored storedKeyring, now time.Time) bool {
+	if len(stored.Keys) == 0 {
+		return true
+	}
+	newest := stored.Keys[len(stored.Keys)-1]
+	return now.Sub(newest.CreatedAt) >= r.e.JWTKeyRotationInterval
+}
+
+// rotate adds a new key to the shared keyring while holding the cluster
+// lock, if it is due or force is set. If another replica holds the lock the
+// keyring is returned unchanged.
+//
+// The caller must hold r.mu.
+func (r *KeyRotator) rotate(ctx context.Context, force bool) (storedKeyring, error) {
+	locked, err := r.c.SetNX(ctx, keyringLockCacheKey, r.id, keyringLockTTL)
+	if err != nil {
+		return storedKeyring{}, fmt.Errorf("failed to acquire rotation lock: %w", err)
+	}
+	if !locked {
+		if force {
+			return storedKeyring{}, errors.New("another rotation is in progress")
+		}
+		return r.load(ctx)
+	}
+	defer func() {
+		// The lock may have expired and been taken by another replica, so
+		// it is only released if it is still ours.
+		if _, err := r.c.DeleteIfEqual(ctx, keyringLockCacheKey, r.id); err != nil {
+			log.Printf("key rotation: failed to release lock: %v", err)
+		}
+	}()
+
+	// Another replica may have rotated between our read and the lock.
+	stored, err := r.load(ctx)
+	if err != nil {
+		return storedKeyring{}, err
+	}
+	now := time.Now()
+	if !force && !r.due(stored, now) {
+		return stored, nil
+	}
+
+	method := r.j.keys.Active().Method
+	k, err := GenerateKey(method)
+	if err != nil {
+		return storedKeyring{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	der, err := marshalPrivateKey(k)
+	if err != nil {
+		return storedKeyring{}, fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	retireAt := now.
+		Add(2 * r.e.JWTKeySyncInterval).
+		Add(r.e.AccessTokenTTL).
+		Add(r.e.JWTKeyRotationGrace)
+
+	keys := make([]storedKey, 0, len(stored.Keys)+1)
+	generated := map[string]bool{}
+	for _, sk := range stored.Keys {
+		generated[sk.ID] = true
+		if sk.RetireAt.IsZero() {
+			sk.RetireAt = retireAt
+		}
+		if now.Before(sk.RetireAt) {
+			keys = append(keys, sk)
+		}
+	}
+	// Keys from the environment are replaced like generated ones. They are
+	// never deleted from the cache, so a replica restarted with the same
+	// environment drops them again.
+	if stored.Retired == nil {
+		stored.Retired = map[string]time.Time{}
+	}
+	for _, k := range r.j.keys.Keys() {
+		if _, ok := stored.Retired[k.ID]; !ok && !generated[k.ID] && !r.managed[k.ID] {
+			stored.Retired[k.ID] = retireAt
+		}
+	}
+	keys = append(keys, storedKey{
+		ID:         k.ID,
+		Alg:        method.Alg(),
+		PrivateKey: der,
+		CreatedAt:  now,
+		ActivateAt: now.Add(2 * r.e.JWTKeySyncInterval),
+	})
+	stored.Keys = keys
+
+	if err := r.c.Set(ctx, keyringCacheKey, stored, 0); err != nil {
+		return storedKeyring{}, fmt.Errorf("failed to store keyring: %w", err)
+	}
+	log.Printf("key rotation: generated signing key %s", k.ID)
+
+	return stored, nil
+}
+
+// apply mirrors the shared keyring into the local one: every stored key is
+// accepted for verification, the newest activated key signs, and keys that
+// were retired from the cache or the environment are dropped.
+//
+// The caller must hold r.mu.
+func (r *KeyRotator) apply(stored storedKeyring, now time.Time) error {
+	kr := r.j.keys
+	seen := map[string]bool{}
+	var active *Key
+
+	for _, sk := range stored.Keys {
+		if !sk.RetireAt.IsZero() && now.After(sk.RetireAt) {
+			continue
+		}
+		seen[sk.ID] = true
+
+		k, ok := kr.Lookup(sk.ID)
+		if !ok {
+			method := jwt.GetSigningMethod(sk.Alg)
+			if method == nil {
+				return fmt.Errorf("stored key %s has unknown algorithm %q", sk.ID, sk.Alg)
+			}
+			var err error
+			if k, err = unmarshalPrivateKey(sk.ID, method, sk.PrivateKey); err != nil {
+				return fmt.Errorf("failed to load stored key %s: %w", sk.ID, err)
+			}
+			kr.Add(k)
+			r.managed[sk.ID] = true
+		}
+
+		if !now.Before(sk.ActivateAt) {
+			active = k
+		}
+	}
+
+	if active != nil && kr.Active() != active {
+		kr.SetActive(active)
+	}
+
+	for kid := range r.managed {
+		if !seen[kid] {
+			kr.Remove(kid)
+			delete(r.managed, kid)
+		}
+	}
+
+	for kid, retireAt := range stored.Retired {
+		if !r.managed[kid] && now.After(retireAt) {
+			kr.Remove(kid)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package jwtutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache/memory"
+)
+
+// newTestReplica returns a JWTUtil and its KeyRotator sharing c with the
+// other replicas.
+func newTestReplica(t testing.TB, c cache.Cache) (*JWTUtil, *KeyRotator) {
+	t.Helper()
+
+	e := &env.Env{
+		Environment:            env.EnvironmentTest,
+		JWTAlgorithm:           "HS256",
+		HMACKey:                "test-hmac-secret-that-is-long-enough",
+		JWTIssuer:              testIssuer,
+		JWTAudience:            []string{testAudience},
+		JWTClaimsMaxBytes:      1024,
+		AccessTokenTTL:         time.Minute,
+		TokenFormat:            TokenFormatJWT,
+		JWTKeyRotationInterval: time.Hour,
+		JWTKeySyncInterval:     time.Minute,
+	}
+	j := NewJWTUtil(e, c)
+	return j, NewKeyRotator(e, c, j)
+}
+
+// storedKeyFor returns the stored key kid, failing the test if it is not
+// in the shared keyring.
+func storedKeyFor(t testing.TB, r *KeyRotator, kid string) storedKey {
+	t.Helper()
+
+	stored, err := r.load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range stored.Keys {
+		if sk.ID == kid {
+			return sk
+		}
+	}
+	t.Fatalf("key %s is not in the shared keyring", kid)
+	return storedKey{}
+}
+
+// applyAt loads the shared keyring into r's JWTUtil as of now.
+func applyAt(t testing.TB, r *KeyRotator, now time.Time) {
+	t.Helper()
+
+	stored, err := r.load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.apply(stored, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewMemory()
+	j1, r1 := newTestReplica(t, c)
+	j2, r2 := newTestReplica(t, c)
+
+	envKid := j1.keys.Active().ID
+	envToken := sign(t, j1, validClaims(), nil)
+
+	first, err := r1.Rotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := j2.keys.Lookup(first); !ok {
+		t.Fatalf("replica did not load the rotated key %s", first)
+	}
+	if got := j2.keys.Active().ID; got != envKid {
+		t.Fatalf("replica signs with %s before activation, want %s", got, envKid)
+	}
+
+	// Once the key activates, tokens signed by one replica verify on the
+	// other.
+	activateAt := storedKeyFor(t, r1, first).ActivateAt
+	applyAt(t, r1, activateAt)
+	applyAt(t, r2, activateAt)
+	if got := j1.keys.Active().ID; got != first {
+		t.Fatalf("active key = %s, want %s", got, first)
+	}
+	token := sign(t, j1, validClaims(), nil)
+	for name, tok := range map[string]string{"rotated key": token, "environment key": envToken} {
+		if _, err := j2.ParseAndVerify(ctx, tok); err != nil {
+			t.Fatalf("replica refused a token signed with the %s: %v", name, err)
+		}
+	}
+
+	// A second rotation retires the first key, and the replica refuses it
+	// after its retirement.
+	second, err := r1.Rotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retireAt := storedKeyFor(t, r1, first).RetireAt
+	if retireAt.IsZero() {
+		t.Fatalf("key %s was not retired by the second rotation", first)
+	}
+	applyAt(t, r2, retireAt.Add(time.Second))
+
+	if got := j2.keys.Active().ID; got != second {
+		t.Fatalf("active key = %s, want %s", got, second)
+	}
+	for name, tok := range map[string]string{"retired key": token, "environment key": envToken} {
+		if _, err := j2.ParseAndVerify(ctx, tok); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("token signed with the %s: error = %v, want ErrUnknownKey", name, err)
+		}
+	}
+}
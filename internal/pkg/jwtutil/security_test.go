@@ -0,0 +1,517 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "jwt-playground"
+	testAudience = "jwt-playground-api"
+)
+
+// testRSAKey is shared by the tests, since generating RSA keys is slow.
+var testRSAKey = mustRSAKey()
+
+func mustRSAKey() *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func newTestJWTUtil(t testing.TB, alg string) *JWTUtil {
+	t.Helper()
+
+	e := &env.Env{
+		Environment:       env.EnvironmentTest,
+		JWTAlgorithm:      alg,
+		JWTIssuer:         testIssuer,
+		JWTAudience:       []string{testAudience},
+		JWTLeeway:         5 * time.Second,
+		JWTClaimsMaxBytes: 1024,
+		AccessTokenTTL:    time.Minute,
+		TokenFormat:       TokenFormatJWT,
+	}
+	if e.IsHMAC() {
+		e.HMACKey = "test-hmac-secret-that-is-long-enough"
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(testRSAKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.JWTPrivateKey = string(pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}))
+	}
+	return NewJWTUtil(e, nil)
+}
+
+// validClaims returns claims that ParseAndVerify accepts.
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{Claims: jwtclaims.Claims{
+		Role: "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Subject:   "1",
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        "jti",
+		},
+	}}
+}
+
+// sign signs the claims with the active key, applying edit to the token
+// before signing.
+func sign(t testing.TB, j *JWTUtil, claims Claims, edit func(*jwt.Token)) string {
+	t.Helper()
+
+	key := j.keys.Active()
+	tok := jwt.NewWithClaims(key.Method, claims)
+	tok.Header["kid"] = key.ID
+	if edit != nil {
+		edit(tok)
+	}
+	s, err := signToken(context.Background(), tok, key.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// unsigned builds a JWT from raw header and payload with the given
+// signature segment.
+func unsigned(header, payload map[string]any, sig string) string {
+	h, _ := json.Marshal(header)
+	p, _ := json.Marshal(payload)
+	return b64.EncodeToString(h) + "." + b64.EncodeToString(p) + "." + sig
+}
+
+func TestParseAndVerifyRejectsAttacks(t *testing.T) {
+	rs := newTestJWTUtil(t, "RS256")
+	hs := newTestJWTUtil(t, "HS256")
+	now := time.Now()
+
+	rsaPublicPEM := func() []byte {
+		der, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}()
+
+	tests := []struct {
+		name   string
+		j      *JWTUtil
+		token  func() string
+		reason error
+	}{
+		{
+			name: "alg none",
+			j:    rs,
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+				s, _ := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "alg none with active kid",
+			j:    hs,
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+				tok.Header["kid"] = hs.keys.Active().ID
+				s, _ := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "alg None capitalized",
+			j:    hs,
+			token: func() string {
+				return unsigned(
+					map[string]any{"alg": "None", "typ": "JWT"},
+					map[string]any{"iss": testIssuer, "aud": testAudience, "exp": now.Add(time.Minute).Unix()},
+					"",
+				)
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "HS256 signed with the RSA public key",
+			j:    rs,
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+				tok.Header["kid"] = rs.keys.Active().ID
+				s, _ := tok.SignedString(rsaPublicPEM)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "RS256 presented to an HMAC deployment",
+			j:    hs,
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+				tok.Header["kid"] = hs.keys.Active().ID
+				s, _ := tok.SignedString(testRSAKey)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "ES256 signed with an attacker key",
+			j:    rs,
+			token: func() string {
+				k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				tok := jwt.NewWithClaims(jwt.SigningMethodES256, validClaims())
+				tok.Header["kid"] = rs.keys.Active().ID
+				s, _ := tok.SignedString(k)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "kid path traversal",
+			j:    rs,
+			token: func() string {
+				return sign(t, rs, validClaims(), func(tok *jwt.Token) {
+					tok.Header["kid"] = "../../../../dev/null"
+				})
+			},
+			reason: ErrUnknownKey,
+		},
+		{
+			name: "kid SQL injection",
+			j:    hs,
+			token: func() string {
+				return sign(t, hs, validClaims(), func(tok *jwt.Token) {
+					tok.Header["kid"] = "x' UNION SELECT 'secret"
+				})
+			},
+			reason: ErrUnknownKey,
+		},
+		{
+			name: "kid not a string",
+			j:    rs,
+			token: func() string {
+				return sign(t, rs, validClaims(), func(tok *jwt.Token) {
+					tok.Header["kid"] = 1
+				})
+			},
+			reason: ErrUnknownKey,
+		},
+		{
+			name: "kid empty",
+			j:    rs,
+			token: func() string {
+				return sign(t, rs, validClaims(), func(tok *jwt.Token) {
+					tok.Header["kid"] = ""
+				})
+			},
+			reason: ErrUnknownKey,
+		},
+		{
+			name: "embedded jwk header is ignored",
+			j:    rs,
+			token: func() string {
+				k, _ := rsa.GenerateKey(rand.Reader, 2048)
+				jwk, _ := NewJWK(&k.PublicKey)
+				tok := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+				tok.Header["kid"] = rs.keys.Active().ID
+				tok.Header["jwk"] = jwk
+				s, _ := tok.SignedString(k)
+				return s
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "tampered payload",
+			j:    rs,
+			token: func() string {
+				parts := strings.Split(sign(t, rs, validClaims(), nil), ".")
+				c := validClaims()
+				c.Role = "admin"
+				p, _ := json.Marshal(c)
+				parts[1] = b64.EncodeToString(p)
+				return strings.Join(parts, ".")
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "tampered header",
+			j:    hs,
+			token: func() string {
+				parts := strings.Split(sign(t, hs, validClaims(), nil), ".")
+				h, _ := json.Marshal(map[string]any{"alg": "HS256", "kid": hs.keys.Active().ID, "typ": "at+jwt"})
+				parts[0] = b64.EncodeToString(h)
+				return strings.Join(parts, ".")
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "signature stripped",
+			j:    rs,
+			token: func() string {
+				parts := strings.Split(sign(t, rs, validClaims(), nil), ".")
+				return parts[0] + "." + parts[1] + "."
+			},
+			reason: ErrInvalidSignature,
+		},
+		{
+			name: "expired",
+			j:    rs,
+			token: func() string {
+				c := validClaims()
+				c.IssuedAt = jwt.NewNumericDate(now.Add(-time.Hour))
+				c.NotBefore = c.IssuedAt
+				c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+				return sign(t, rs, c, nil)
+			},
+			reason: ErrTokenExpired,
+		},
+		{
+			name: "missing exp",
+			j:    hs,
+			token: func() string {
+				c := validClaims()
+				c.ExpiresAt = nil
+				return sign(t, hs, c, nil)
+			},
+			reason: ErrMissingClaim,
+		},
+		{
+			name: "not yet valid",
+			j:    rs,
+			token: func() string {
+				c := validClaims()
+				c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour))
+				return sign(t, rs, c, nil)
+			},
+			reason: ErrTokenNotYetValid,
+		},
+		{
+			name: "issued in the future",
+			j:    hs,
+			token: func() string {
+				c := validClaims()
+				c.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour))
+				return sign(t, hs, c, nil)
+			},
+			reason: ErrTokenNotYetValid,
+		},
+		{
+			name: "wrong issuer",
+			j:    rs,
+			token: func() string {
+				c := validClaims()
+				c.Issuer = "https://evil.example"
+				return sign(t, rs, c, nil)
+			},
+			reason: ErrInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			j:    hs,
+			token: func() string {
+				c := validClaims()
+				c.Audience = jwt.ClaimStrings{"another-api"}
+				return sign(t, hs, c, nil)
+			},
+			reason: ErrInvalidAudience,
+		},
+		{
+			name: "oversized",
+			j:    hs,
+			token: func() string {
+				c := validClaims()
+				c.Custom = map[string]any{"pad": strings.Repeat("a", maxTokenBytes)}
+				return sign(t, hs, c, nil)
+			},
+			reason: ErrTokenTooLarge,
+		},
+		{
+			name:   "empty",
+			j:      rs,
+			token:  func() string { return "" },
+			reason: ErrTokenMalformed,
+		},
+		{
+			name:   "two segments",
+			j:      rs,
+			token:  func() string { return "eyJhbGciOiJIUzI1NiJ9.e30" },
+			reason: ErrTokenMalformed,
+		},
+		{
+			name:   "not base64",
+			j:      hs,
+			token:  func() string { return "!!!.???.***" },
+			reason: ErrTokenMalformed,
+		},
+		{
+			name: "header not JSON",
+			j:    hs,
+			token: func() string {
+				return base64.RawURLEncoding.EncodeToString([]byte("not json")) + ".e30.sig"
+			},
+			reason: ErrTokenMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.j.ParseAndVerify(context.Background(), tt.token())
+			if err == nil {
+				t.Fatalf("token accepted with claims %+v", claims)
+			}
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error %v is not a *VerificationError", err)
+			}
+			if !errors.Is(err, tt.reason) {
+				t.Fatalf("got reason %v, want %v", verr.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParseAndVerifyAcceptsValidTokens(t *testing.T) {
+	for _, alg := range []string{"RS256", "HS256"} {
+		t.Run(alg, func(t *testing.T) {
+			j := newTestJWTUtil(t, alg)
+			claims, err := j.ParseAndVerify(
+				context.Background(),
+				sign(t, j, validClaims(), nil),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "1" || claims.Role != "user" {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestParseAndVerifyLeeway(t *testing.T) {
+	j := newTestJWTUtil(t, "HS256")
+	c := validClaims()
+	c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Second))
+	if _, err := j.ParseAndVerify(context.Background(), sign(t, j, c, nil)); err != nil {
+		t.Fatalf("token expired within leeway rejected: %v", err)
+	}
+}
+
+func TestVerifyCapabilityPathPrefix(t *testing.T) {
+	j := newTestJWTUtil(t, "HS256")
+	j.capKey = []byte("test-capability-key-of-32-bytes!")
+
+	tests := []struct {
+		prefix string
+		path   string
+		want   bool
+	}{
+		{"/admin/revoke", "/admin/revoke", true},
+		{"/admin/revoke", "/admin/revoke/1", true},
+		{"/admin/revoke", "/admin/revoke-all", false},
+		{"/admin/revoke", "/admin/revoke-user", false},
+		{"/docs", "/docs/a", true},
+		{"/docs", "/docsecret", false},
+		{"/docs/", "/docs/a", true},
+		{"/docs/", "/docs", false},
+		{"/docs/", "/docsecret", false},
+		{"/docs/", "/docs/../admin", false},
+		{"/", "/anything", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.path, func(t *testing.T) {
+			token, err := j.MintCapability(
+				TokenSubject{UserID: "1"},
+				time.Minute,
+				"path prefix "+tt.prefix,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := CapabilityRequest{Method: "GET", Path: tt.path, Time: time.Now()}
+			_, err = j.VerifyCapability(token, req)
+			if got := err == nil; got != tt.want {
+				t.Fatalf("accepted = %v, want %v (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func FuzzParseAndVerify(f *testing.F) {
+	j := newTestJWTUtil(f, "HS256")
+	valid := sign(f, j, validClaims(), nil)
+	parts := strings.Split(valid, ".")
+
+	f.Add(valid)
+	f.Add(parts[0] + "." + parts[1] + ".")
+	f.Add(parts[0] + ".." + parts[2])
+	f.Add("eyJhbGciOiJub25lIn0.e30.")
+	f.Add("...")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, token string) {
+		claims, err := j.ParseAndVerify(context.Background(), token)
+		if err != nil {
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error %v is not a *VerificationError", err)
+			}
+			return
+		}
+		if claims.Issuer != testIssuer || !j.acceptsAudience(claims.Audience) {
+			t.Fatalf("accepted claims %+v", claims)
+		}
+	})
+}
+
+func FuzzVerifyCapability(f *testing.F) {
+	j := newTestJWTUtil(f, "HS256")
+	j.capKey = []byte("test-capability-key-of-32-bytes!")
+	valid, err := j.MintCapability(TokenSubject{UserID: "1"}, time.Minute, "path prefix /docs/")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(valid, "GET", "/docs/a")
+	f.Add(valid, "GET", "/docs/../admin")
+	f.Add("cap.", "GET", "/")
+	f.Add("cap...", "POST", "")
+
+	f.Fuzz(func(t *testing.T, token, method, path string) {
+		req := CapabilityRequest{Method: method, Path: path, Time: time.Now()}
+		if _, err := j.VerifyCapability(token, req); err != nil {
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error %v is not a *VerificationError", err)
+			}
+		}
+	})
+}
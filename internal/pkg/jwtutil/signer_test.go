@@ -0,0 +1,214 @@
+package jwtutil
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/config/env"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSignerKeyID = "remote-key"
+
+// keyService returns a handler that signs like an external key service
+// holding priv, failing the test if the request does not name the key.
+func keyService(t testing.TB, priv *rsa.PrivateKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Kid          string `json:"kid"`
+			Alg          string `json:"alg"`
+			SigningInput string `json:"signing_input"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/sign" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Kid != testSignerKeyID || req.Alg != "RS256" {
+			t.Errorf("key service got kid %q and alg %q", req.Kid, req.Alg)
+		}
+		input, err := b64.DecodeString(req.SigningInput)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := jwt.SigningMethodRS256.Sign(string(input), priv)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"signature": b64.EncodeToString(sig),
+		})
+	}
+}
+
+// newRemoteJWTUtil returns a JWTUtil signing through the key service at
+// signerURL, verifying with the public half of testRSAKey.
+func newRemoteJWTUtil(t testing.TB, signerURL string) *JWTUtil {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubFile := filepath.Join(t.TempDir(), "signer.pub")
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(pubFile, pubPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewJWTUtil(&env.Env{
+		Environment:            env.EnvironmentTest,
+		JWTAlgorithm:           "RS256",
+		JWTKeyID:               testSignerKeyID,
+		JWTSignerURL:           signerURL,
+		JWTSignerPublicKeyFile: pubFile,
+		JWTIssuer:              testIssuer,
+		JWTAudience:            []string{testAudience},
+		JWTClaimsMaxBytes:      1024,
+		AccessTokenTTL:         time.Minute,
+		TokenFormat:            TokenFormatJWT,
+	}, nil)
+}
+
+func TestRemoteSigner(t *testing.T) {
+	srv := httptest.NewServer(keyService(t, testRSAKey))
+	defer srv.Close()
+
+	j := newRemoteJWTUtil(t, srv.URL+"/")
+	token := sign(t, j, validClaims(), nil)
+	if _, err := j.ParseAndVerify(context.Background(), token); err != nil {
+		t.Fatalf("token signed remotely does not verify: %v", err)
+	}
+}
+
+func TestRemoteSignerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &httptest.Server{
+		Listener: l,
+		Config:   &http.Server{Handler: keyService(t, testRSAKey)},
+	}
+	srv.Start()
+	defer srv.Close()
+
+	j := newRemoteJWTUtil(t, "unix://"+socket)
+	token := sign(t, j, validClaims(), nil)
+	if _, err := j.ParseAndVerify(context.Background(), token); err != nil {
+		t.Fatalf("token signed over the socket does not verify: %v", err)
+	}
+}
+
+func TestRemoteSignerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "error status",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "hsm unavailable", http.StatusServiceUnavailable)
+			},
+			want: "unexpected status 503",
+		},
+		{
+			name: "invalid json",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte("{"))
+			},
+			want: "invalid response",
+		},
+		{
+			name: "invalid signature encoding",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"signature": "not base64url!"}`))
+			},
+			want: "invalid signature encoding",
+		},
+		{
+			name: "slow service",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				// The request context only ends on disconnect once the
+				// body has been read.
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			},
+			want: "context deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			s, err := NewRemoteSigner(srv.URL, testSignerKeyID, "RS256")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			if _, err := s.Sign(ctx, []byte("header.payload")); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Sign() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteSignerUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	s, err := NewRemoteSigner(srv.URL, testSignerKeyID, "RS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sign(context.Background(), []byte("header.payload")); err == nil {
+		t.Fatal("Sign() succeeded with the key service down")
+	}
+}
+
+// A key service signing with another key produces tokens that do not
+// verify against the configured public key.
+func TestRemoteSignerWrongKey(t *testing.T) {
+	other := mustRSAKey()
+	srv := httptest.NewServer(keyService(t, other))
+	defer srv.Close()
+
+	j := newRemoteJWTUtil(t, srv.URL)
+	token := sign(t, j, validClaims(), nil)
+	if _, err := j.ParseAndVerify(context.Background(), token); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("ParseAndVerify() error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewRemoteSignerRejectsURL(t *testing.T) {
+	for _, rawURL := range []string{"ftp://signer", "signer:8080", "://signer"} {
+		t.Run(rawURL, func(t *testing.T) {
+			if _, err := NewRemoteSigner(rawURL, testSignerKeyID, "RS256"); err == nil {
+				t.Fatalf("NewRemoteSigner(%q) succeeded", rawURL)
+			}
+		})
+	}
+}
@@ -15,8 +15,32 @@ type Cache interface {
 		expiration time.Duration,
 	) error
 
+	// SetNX sets the key only if it does not exist yet, reporting whether
+	// the value was stored.
+	SetNX(
+		ctx context.Context,
+		key string,
+		value any,
+		expiration time.Duration,
+	) (ok bool, err error)
+
 	Delete(
 		ctx context.Context,
 		keys ...string,
 	) error
+
+	// DeleteIfEqual deletes the key only if it holds value, reporting
+	// whether it was deleted.
+	DeleteIfEqual(
+		ctx context.Context,
+		key string,
+		value any,
+	) (ok bool, err error)
+
+	// Keys returns the keys matching pattern, a glob in which * matches any
+	// sequence of characters.
+	Keys(
+		ctx context.Context,
+		pattern string,
+	) ([]string, error)
 }
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/internal/provider/cache"
+)
+
+type entry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Memory is an in-process cache for tests and tools that run without
+// Redis. Values are stored as JSON, and every operation holds one lock,
+// so SetNX and DeleteIfEqual are atomic like their Redis counterparts.
+type Memory struct {
+	mu sync.Mutex
+	m  map[string]entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		m: map[string]entry{},
+	}
+}
+
+// get returns the live entry for key, dropping it if it has expired. The
+// caller must hold mu.
+func (m *Memory) get(key string) (entry, bool) {
+	e, ok := m.m[key]
+	if ok && e.expired(time.Now()) {
+		delete(m.m, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+// set stores value under key. The caller must hold mu.
+func (m *Memory) set(
+	key string,
+	value any,
+	expiration time.Duration,
+) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	e := entry{data: data}
+	if expiration > 0 {
+		e.expiresAt = time.Now().Add(expiration)
+	}
+	m.m[key] = e
+	return nil
+}
+
+func (m *Memory) Scan(
+	_ context.Context,
+	key string,
+	value any,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.get(key)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(e.data, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *Memory) Set(
+	_ context.Context,
+	key string,
+	value any,
+	expiration time.Duration,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.set(key, value, expiration)
+}
+
+func (m *Memory) SetNX(
+	_ context.Context,
+	key string,
+	value any,
+	expiration time.Duration,
+) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, taken := m.get(key); taken {
+		return false, nil
+	}
+	if err := m.set(key, value, expiration); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *Memory) Delete(
+	_ context.Context,
+	keys ...string,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range keys {
+		delete(m.m, k)
+	}
+	return nil
+}
+
+func (m *Memory) DeleteIfEqual(
+	_ context.Context,
+	key string,
+	value any,
+) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.get(key)
+	if !ok || !bytes.Equal(e.data, data) {
+		return false, nil
+	}
+	delete(m.m, key)
+	return true, nil
+}
+
+// Keys matches with path.Match, so unlike in Redis a * does not match a
+// slash.
+func (m *Memory) Keys(
+	_ context.Context,
+	pattern string,
+) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for k := range m.m {
+		if _, ok := m.get(k); !ok {
+			continue
+		}
+		if ok, _ := path.Match(pattern, k); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+var _ cache.Cache = (*Memory)(nil)
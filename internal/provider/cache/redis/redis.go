@@ -98,22 +98,28 @@ func (r *Redis) Set(
 	value any,
 	expiration time.Duration,
 ) error {
-	var data any
-
-	switch v := value.(type) {
-	case string, []byte, int, int64, float64, bool:
-		data = v
-	default:
-		b, err := json.Marshal(value)
-		if err != nil {
-			return err
-		}
-		data = b
+	data, err := encode(value)
+	if err != nil {
+		return err
 	}
 
 	return r.c.Set(ctx, key, data, expiration).Err()
 }
 
+func (r *Redis) SetNX(
+	ctx context.Context,
+	key string,
+	value any,
+	expiration time.Duration,
+) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+
+	return r.c.SetNX(ctx, key, data, expiration).Result()
+}
+
 func (r *Redis) Delete(
 	ctx context.Context,
 	keys ...string,
@@ -123,4 +129,61 @@ func (r *Redis) Delete(
 	return r.c.Del(ctx, ks...).Err()
 }
 
+// deleteIfEqualScript compares and deletes atomically.
+var deleteIfEqualScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r *Redis) DeleteIfEqual(
+	ctx context.Context,
+	key string,
+	value any,
+) (bool, error) {
+	data, err := encode(value)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := deleteIfEqualScript.Run(ctx, r.c, []string{key}, data).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Keys iterates with SCAN rather than KEYS, so that it does not block the
+// server. SCAN may return a key more than once; duplicates are dropped.
+func (r *Redis) Keys(
+	ctx context.Context,
+	pattern string,
+) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	iter := r.c.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		if k := iter.Val(); !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys, iter.Err()
+}
+
+// encode stores scalar values as-is and everything else as JSON.
+func encode(value any) (any, error) {
+	switch v := value.(type) {
+	case string, []byte, int, int64, float64, bool:
+		return v, nil
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
 var _ cache.Cache = (*Redis)(nil)
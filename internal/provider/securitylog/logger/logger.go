@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/provider/securitylog"
+)
+
+// Logger writes security events to the standard logger as JSON, one event
+// per line, for log pipelines to parse and alert on.
+type Logger struct{}
+
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+func (l *Logger) Record(_ context.Context, event entity.SecurityEvent) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode security event %q: %v", event.Type, err)
+		return
+	}
+	log.Printf("security: %s", b)
+}
+
+var _ securitylog.SecurityLog = (*Logger)(nil)
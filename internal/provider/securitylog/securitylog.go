@@ -0,0 +1,14 @@
+package securitylog
+
+import (
+	"context"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+)
+
+// SecurityLog receives security events. Recording must not fail the
+// request that caused the event, so errors are the implementation's to
+// handle.
+type SecurityLog interface {
+	Record(ctx context.Context, event entity.SecurityEvent)
+}
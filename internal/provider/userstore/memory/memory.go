@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+	"github.com/dyegopenha/jwt-playground/internal/provider/userstore"
+)
+
+// Memory is a read-only user store seeded with the playground's demo users.
+type Memory struct {
+	users []entity.User
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users: []entity.User{
+			{
+				ID:           "1",
+				Email:        "test@example.com",
+				Role:         "admin",
+				TenantID:     "playground",
+				Groups:       []string{"admins"},
+				Entitlements: []string{"profile:read", "tokens:revoke"},
+			},
+		},
+	}
+}
+
+func (m *Memory) FindByID(
+	_ context.Context,
+	id string,
+) (*entity.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return nil, userstore.ErrUserNotFound
+}
+
+func (m *Memory) FindByEmail(
+	_ context.Context,
+	email string,
+) (*entity.User, error) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, userstore.ErrUserNotFound
+}
+
+var _ userstore.UserStore = (*Memory)(nil)
@@ -0,0 +1,16 @@
+package userstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dyegopenha/jwt-playground/internal/domain/entity"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+type UserStore interface {
+	FindByID(ctx context.Context, id string) (*entity.User, error)
+
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+}
@@ -0,0 +1,311 @@
+// Package client signs in to jwt-playground and keeps an access token
+// fresh for outbound requests.
+//
+//	c, err := client.New(client.Config{BaseURL: "https://auth.example.com"})
+//	...
+//	if err := c.SignIn(ctx, "test@example.com", "password"); err != nil {
+//		...
+//	}
+//	resp, err := c.HTTPClient().Get("https://api.example.com/orders")
+//
+// The access token is refreshed shortly before it expires, and after a
+// request is rejected with 401. Concurrent refreshes are merged into one,
+// since the server rotates the refresh token on every use and presenting an
+// old one again revokes the session.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	refreshCookieName = "refresh_token"
+
+	defaultRefreshBefore = 30 * time.Second
+	defaultHTTPTimeout   = 10 * time.Second
+
+	// maxResponseBytes bounds the size of sign-in and refresh responses.
+	maxResponseBytes = 1 << 20
+)
+
+var (
+	// ErrNotSignedIn is returned before SignIn has succeeded.
+	ErrNotSignedIn = errors.New("client: not signed in")
+	// ErrSessionExpired is returned when the server refuses the refresh
+	// token, and the user has to sign in again.
+	ErrSessionExpired = errors.New("client: session expired")
+)
+
+// Config configures a Client. BaseURL is required.
+type Config struct {
+	// BaseURL is the jwt-playground server, e.g. https://auth.example.com.
+	BaseURL string
+	// RefreshBefore is how long before the access token expires it is
+	// refreshed. Defaults to 30 seconds.
+	RefreshBefore time.Duration
+	// HTTPClient calls the sign-in and refresh endpoints. Defaults to a
+	// client with a 10 second timeout.
+	HTTPClient *http.Client
+	// Transport sends the outbound requests made through HTTPClient and
+	// Transport. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// Client holds a user's session: the access token, when it expires, and
+// the refresh token. It is safe for concurrent use.
+type Client struct {
+	cfg     Config
+	baseURL *url.URL
+
+	mu           sync.Mutex
+	accessToken  string
+	expiresAt    time.Time
+	refreshToken string
+	// inflight is the refresh in progress, if any.
+	inflight *refresh
+}
+
+// refresh is a refresh shared by every caller that needs it.
+type refresh struct {
+	done chan struct{}
+	err  error
+}
+
+// New creates a Client. Call SignIn before making requests.
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("client: BaseURL is required")
+	}
+	baseURL, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid BaseURL: %w", err)
+	}
+	if cfg.RefreshBefore <= 0 {
+		cfg.RefreshBefore = defaultRefreshBefore
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+
+	return &Client{
+		cfg:     cfg,
+		baseURL: baseURL,
+	}, nil
+}
+
+// SignIn signs in with the user's credentials and starts a new session.
+func (c *Client) SignIn(ctx context.Context, email, password string) error {
+	body, err := json.Marshal(map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	if err != nil {
+		return err
+	}
+
+	tokens, err := c.call(ctx, "/sign-in", bytes.NewReader(body), nil)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(tokens)
+	return nil
+}
+
+// Token returns a valid access token, refreshing it first when it expires
+// within RefreshBefore. If the refresh fails while the current token is
+// still valid, the current token is returned.
+func (c *Client) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expiresAt := c.accessToken, c.expiresAt
+	c.mu.Unlock()
+
+	if token == "" {
+		return "", ErrNotSignedIn
+	}
+	if expiresAt.IsZero() || time.Until(expiresAt) > c.cfg.RefreshBefore {
+		return token, nil
+	}
+
+	if err := c.refresh(ctx, token); err != nil {
+		if time.Now().Before(expiresAt) {
+			return token, nil
+		}
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken, nil
+}
+
+// Refresh replaces the access token now, for instance after it has been
+// revoked.
+func (c *Client) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	token := c.accessToken
+	c.mu.Unlock()
+
+	if token == "" {
+		return ErrNotSignedIn
+	}
+	return c.refresh(ctx, token)
+}
+
+// refresh replaces the stale access token. Callers holding the same stale
+// token share a single call to /refresh, and a token that has already been
+// replaced is not refreshed again.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.mu.Lock()
+	if c.accessToken != stale {
+		c.mu.Unlock()
+		return nil
+	}
+	f := c.inflight
+	if f == nil {
+		f = &refresh{done: make(chan struct{})}
+		c.inflight = f
+		// The refresh must not be cut short by the caller that started
+		// it: once the server has rotated the refresh token, losing the
+		// response would end the session.
+		go c.doRefresh(context.WithoutCancel(ctx), f, c.refreshToken)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) doRefresh(ctx context.Context, f *refresh, refreshToken string) {
+	cookie := &http.Cookie{Name: refreshCookieName, Value: refreshToken}
+	tokens, err := c.call(ctx, "/refresh", nil, cookie)
+
+	c.mu.Lock()
+	if err == nil {
+		c.store(tokens)
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	f.err = err
+	close(f.done)
+}
+
+// tokens is a sign-in or refresh response.
+type tokens struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	refreshToken string
+}
+
+// store replaces the session with the new tokens. c.mu must be held.
+func (c *Client) store(t *tokens) {
+	c.accessToken = t.AccessToken
+	c.refreshToken = t.refreshToken
+	c.expiresAt = time.Time{}
+	if t.ExpiresIn > 0 {
+		c.expiresAt = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	} else if exp, ok := jwtExpiry(t.AccessToken); ok {
+		c.expiresAt = exp
+	}
+}
+
+// call posts to a sign-in or refresh endpoint and reads the tokens.
+func (c *Client) call(
+	ctx context.Context,
+	path string,
+	body io.Reader,
+	cookie *http.Cookie,
+) (*tokens, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		c.baseURL.JoinPath(path).String(),
+		body,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client: %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized && path == "/refresh":
+		return nil, ErrSessionExpired
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf(
+			"client: %s failed: status %d: %s",
+			path,
+			resp.StatusCode,
+			strings.TrimSpace(string(msg)),
+		)
+	}
+
+	t := &tokens{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(t); err != nil {
+		return nil, fmt.Errorf("client: invalid %s response: %w", path, err)
+	}
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("client: %s returned no access token", path)
+	}
+	for _, ck := range resp.Cookies() {
+		if ck.Name == refreshCookieName {
+			t.refreshToken = ck.Value
+		}
+	}
+	if t.refreshToken == "" {
+		return nil, fmt.Errorf("client: %s returned no refresh token", path)
+	}
+	return t, nil
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it. The client
+// only uses it to schedule refreshes.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
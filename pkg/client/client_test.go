@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// server fakes the jwt-playground endpoints the client uses, plus an API
+// that accepts only the current access token. Like the real server, it
+// rotates the refresh token on every refresh and refuses old ones.
+type server struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	issued  int
+	access  string
+	refresh string
+
+	refreshes atomic.Int32
+}
+
+func newServer(t testing.TB) *server {
+	t.Helper()
+
+	s := &server{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sign-in", s.signIn)
+	mux.HandleFunc("POST /refresh", s.refreshTokens)
+	mux.HandleFunc("/api", s.api)
+	s.srv = httptest.NewServer(mux)
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *server) signIn(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Password != "password" {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	s.issue(w)
+}
+
+func (s *server) refreshTokens(w http.ResponseWriter, r *http.Request) {
+	s.refreshes.Add(1)
+	// Give concurrent callers time to pile up behind this refresh.
+	time.Sleep(20 * time.Millisecond)
+
+	ck, err := r.Cookie(refreshCookieName)
+	s.mu.Lock()
+	ok := err == nil && s.refresh != "" && ck.Value == s.refresh
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	s.issue(w)
+}
+
+func (s *server) issue(w http.ResponseWriter) {
+	s.mu.Lock()
+	s.issued++
+	s.access = fmt.Sprintf("access-%d", s.issued)
+	s.refresh = fmt.Sprintf("refresh-%d", s.issued)
+	access, refresh := s.access, s.refresh
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: refreshCookieName, Value: refresh})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": access,
+		"expires_in":   3600,
+	})
+}
+
+// api echoes the request body to callers presenting the current access
+// token.
+func (s *server) api(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ok := s.access != "" && r.Header.Get("Authorization") == "Bearer "+s.access
+	s.mu.Unlock()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	io.Copy(w, r.Body)
+}
+
+// revokeAccess makes the API refuse the current access token.
+func (s *server) revokeAccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access = ""
+}
+
+// revokeSession makes the API and /refresh refuse the current tokens.
+func (s *server) revokeSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access = ""
+	s.refresh = ""
+}
+
+func (s *server) signedIn(t testing.TB) *Client {
+	t.Helper()
+
+	c, err := New(Config{BaseURL: s.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SignIn(context.Background(), "test@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConcurrentUnauthorizedRefreshOnce(t *testing.T) {
+	s := newServer(t)
+	c := s.signedIn(t)
+	hc := c.HTTPClient()
+
+	s.revokeAccess()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := hc.Get(s.srv.URL + "/api")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := s.refreshes.Load(); n != 1 {
+		t.Fatalf("refreshed %d times, want 1", n)
+	}
+}
+
+func TestRetryAfterRefresh(t *testing.T) {
+	s := newServer(t)
+	c := s.signedIn(t)
+	hc := c.HTTPClient()
+
+	s.revokeAccess()
+
+	resp, err := hc.Post(s.srv.URL+"/api", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if string(body) != "payload" {
+		t.Fatalf("retried body = %q, want %q", body, "payload")
+	}
+	if n := s.refreshes.Load(); n != 1 {
+		t.Fatalf("refreshed %d times, want 1", n)
+	}
+}
+
+func TestNoRetryWithoutRewindableBody(t *testing.T) {
+	s := newServer(t)
+	c := s.signedIn(t)
+	hc := c.HTTPClient()
+
+	s.revokeAccess()
+
+	// An io.Reader without a GetBody cannot be sent twice.
+	body := io.MultiReader(strings.NewReader("payload"))
+	resp, err := hc.Post(s.srv.URL+"/api", "text/plain", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if n := s.refreshes.Load(); n != 0 {
+		t.Fatalf("refreshed %d times, want 0", n)
+	}
+}
+
+func TestRefreshTokenRejected(t *testing.T) {
+	s := newServer(t)
+	c := s.signedIn(t)
+	hc := c.HTTPClient()
+
+	s.revokeSession()
+
+	// The 401 is passed on to the caller, since retrying cannot help.
+	resp, err := hc.Get(s.srv.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	if err := c.Refresh(context.Background()); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Refresh() error = %v, want ErrSessionExpired", err)
+	}
+}
+
+func TestNotSignedIn(t *testing.T) {
+	s := newServer(t)
+	c, err := New(Config{BaseURL: s.srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.HTTPClient().Get(s.srv.URL + "/api"); !errors.Is(err, ErrNotSignedIn) {
+		t.Fatalf("Get() error = %v, want ErrNotSignedIn", err)
+	}
+	if err := c.Refresh(context.Background()); !errors.Is(err, ErrNotSignedIn) {
+		t.Fatalf("Refresh() error = %v, want ErrNotSignedIn", err)
+	}
+}
+
+func TestTokenRefreshesBeforeExpiry(t *testing.T) {
+	s := newServer(t)
+	c := s.signedIn(t)
+
+	// Pretend the access token expires within RefreshBefore.
+	c.mu.Lock()
+	c.expiresAt = time.Now().Add(time.Second)
+	c.mu.Unlock()
+
+	token, err := c.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access-2" {
+		t.Fatalf("Token() = %q, want the refreshed token", token)
+	}
+	if n := s.refreshes.Load(); n != 1 {
+		t.Fatalf("refreshed %d times, want 1", n)
+	}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"io"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that authenticates requests with the
+// client's access token.
+type Transport struct {
+	c *Client
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// Transport returns a RoundTripper that adds "Authorization: Bearer" to
+// every request, sending it through Config.Transport.
+func (c *Client) Transport() *Transport {
+	return &Transport{c: c}
+}
+
+// HTTPClient returns an http.Client whose requests are authenticated with
+// the client's access token.
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.Transport()}
+}
+
+// RoundTrip sends the request with a fresh access token. When the response
+// is 401, the token is refreshed and the request retried once, provided
+// its body can be sent again.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.c.Token(req.Context())
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	resp, err := t.c.cfg.Transport.RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	// The token may have been revoked or rotated; refresh unless another
+	// request already did.
+	if err := t.c.refresh(req.Context(), token); err != nil {
+		return resp, nil
+	}
+	retry, err := t.c.Token(req.Context())
+	if err != nil || retry == token {
+		return resp, nil
+	}
+
+	next := authorize(req, retry)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		next.Body = body
+	}
+	drain(resp)
+	return t.c.cfg.Transport.RoundTrip(next)
+}
+
+// authorize returns a copy of the request carrying the access token, since
+// a RoundTripper must not modify its request.
+func authorize(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// drain discards a response that is not returned to the caller, so its
+// connection can be reused.
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+}
+
+// closeBody closes the request body, which a RoundTripper must do even when
+// it fails.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
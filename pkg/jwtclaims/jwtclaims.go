@@ -0,0 +1,134 @@
+// Package jwtclaims defines the claims of the access tokens issued by
+// jwt-playground. The server and pkg/verifier share it, so both read and
+// write tokens the same way.
+package jwtclaims
+
+import (
+	"encoding/json"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// reserved are the claims decoded into Claims fields rather than Custom.
+var reserved = map[string]bool{
+	"iss":   true,
+	"sub":   true,
+	"aud":   true,
+	"exp":   true,
+	"nbf":   true,
+	"iat":   true,
+	"jti":   true,
+	"role":  true,
+	"cnf":   true,
+	"scope": true,
+	"act":   true,
+	"sid":   true,
+}
+
+// IsReserved reports whether name is a claim with its own Claims field,
+// which custom claims may not override.
+func IsReserved(name string) bool {
+	return reserved[name]
+}
+
+// Claims are the claims of an access token. It embeds jwt.RegisteredClaims
+// to take advantage of the built-in validations provided by the golang-jwt
+// library.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+	// Confirmation is set on sender-constrained tokens.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	// Scope is the space-separated list of scopes the token grants.
+	Scope string `json:"scope,omitempty"`
+	// Actor is set on tokens used on behalf of their subject.
+	Actor *Actor `json:"act,omitempty"`
+	// SessionID is the refresh session the token was issued from. The
+	// token is revoked along with the session.
+	SessionID string `json:"sid,omitempty"`
+	// Custom holds every other claim, such as those filled from the
+	// issuer's claims template. They are serialized as top-level claims.
+	Custom map[string]any `json:"-"`
+}
+
+// Confirmation is the RFC 7800 cnf claim, which binds a token to a key the
+// client must prove possession of.
+type Confirmation struct {
+	// JKT is the RFC 7638 thumbprint of the client's DPoP key.
+	JKT string `json:"jkt,omitempty"`
+	// X5TS256 is the RFC 8705 SHA-256 thumbprint of the client's TLS
+	// certificate.
+	X5TS256 string `json:"x5t#S256,omitempty"`
+}
+
+// IsZero reports whether the confirmation binds nothing.
+func (c Confirmation) IsZero() bool {
+	return c == Confirmation{}
+}
+
+// Actor is the RFC 8693 act claim, naming the party acting on behalf of the
+// token's subject. Nested actors record earlier delegations, most recent
+// first.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// Depth returns the number of actors in the chain.
+func (a *Actor) Depth() int {
+	n := 0
+	for ; a != nil; a = a.Actor {
+		n++
+	}
+	return n
+}
+
+// claimsFields is Claims without its JSON methods.
+type claimsFields Claims
+
+// MarshalJSON flattens the custom claims into the top-level JSON object.
+// Custom claims never override reserved or registered ones.
+func (c Claims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(claimsFields(c))
+	if err != nil || len(c.Custom) == 0 {
+		return b, err
+	}
+
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return nil, err
+	}
+	for name, value := range c.Custom {
+		if _, taken := merged[name]; taken || reserved[name] {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		merged[name] = raw
+	}
+	return json.Marshal(merged)
+}
+
+// UnmarshalJSON collects every claim that is not a known field into Custom.
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*claimsFields)(c)); err != nil {
+		return err
+	}
+
+	all := map[string]any{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	for name := range all {
+		if reserved[name] {
+			delete(all, name)
+		}
+	}
+	c.Custom = nil
+	if len(all) > 0 {
+		c.Custom = all
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package verifier
+
+import "github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+
+// Claims are the claims of a verified access token. Custom holds every
+// claim without a field of its own, such as those filled from the issuer's
+// claims template.
+type Claims = jwtclaims.Claims
+
+// Confirmation is the RFC 7800 cnf claim, which binds a token to a key the
+// client must prove possession of.
+type Confirmation = jwtclaims.Confirmation
+
+// Actor is the RFC 8693 act claim, naming the party acting on behalf of the
+// token's subject.
+type Actor = jwtclaims.Actor
@@ -0,0 +1,165 @@
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/pkg/jwk"
+)
+
+// maxKeySetBytes bounds the size of a fetched key set.
+const maxKeySetBytes = 1 << 20
+
+// publicKey is a parsed verification key.
+type publicKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet is a cached copy of a remote JWK Set. It is refreshed in the
+// background and whenever a token names a kid it does not know, at most
+// once per minRefresh so unknown kids cannot be used to flood the issuer.
+type keySet struct {
+	url        string
+	client     *http.Client
+	minRefresh time.Duration
+	onError    func(error)
+	// ready is closed once the first fetch has been attempted.
+	ready chan struct{}
+
+	mu   sync.RWMutex
+	keys map[string]publicKey
+	// lastMiss is when an unknown kid last triggered a fetch.
+	lastMiss time.Time
+
+	// fetchMu makes concurrent lookups of an unknown kid share one fetch.
+	fetchMu sync.Mutex
+}
+
+// lookup returns the key with the given kid, fetching the key set again
+// when the kid is unknown.
+func (s *keySet) lookup(ctx context.Context, kid string) (publicKey, error) {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return publicKey{}, fmt.Errorf("%w: %w", ErrKeySetUnavailable, ctx.Err())
+	}
+
+	if k, ok := s.get(kid); ok {
+		return k, nil
+	}
+
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	if k, ok := s.get(kid); ok {
+		return k, nil
+	}
+
+	s.mu.Lock()
+	recent := time.Since(s.lastMiss) < s.minRefresh
+	if !recent {
+		s.lastMiss = time.Now()
+	}
+	empty := len(s.keys) == 0
+	s.mu.Unlock()
+	if recent {
+		if empty {
+			return publicKey{}, ErrKeySetUnavailable
+		}
+		return publicKey{}, ErrUnknownKey
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		if empty {
+			return publicKey{}, fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+		}
+		return publicKey{}, ErrUnknownKey
+	}
+	if k, ok := s.get(kid); ok {
+		return k, nil
+	}
+	return publicKey{}, ErrUnknownKey
+}
+
+func (s *keySet) get(kid string) (publicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// refresh fetches the key set and replaces the cached keys. Keys that are
+// not signing keys or cannot be parsed are skipped. On failure the cached
+// keys are kept.
+func (s *keySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch key set: status %d", resp.StatusCode)
+	}
+
+	var set jwk.Set
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeySetBytes)).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode key set: %w", err)
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			s.onError(fmt.Errorf("skipping key %q: %w", k.Kid, err))
+			continue
+		}
+		keys[k.Kid] = publicKey{alg: k.Alg, key: pub}
+	}
+	if len(keys) == 0 {
+		return errors.New("key set has no usable signing keys")
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// run refreshes the key set every interval until ctx is done.
+func (s *keySet) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		s.fetchMu.Lock()
+		err := s.refresh(ctx)
+		s.fetchMu.Unlock()
+		if first {
+			close(s.ready)
+		}
+		if err != nil && ctx.Err() == nil {
+			s.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
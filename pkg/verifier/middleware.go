@@ -0,0 +1,99 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+type ctxKey struct{}
+
+// Middleware verifies the "Authorization: Bearer <token>" header and
+// attaches the claims to the request context, where handlers read them
+// with CurrentUser. Certificate-bound tokens are only accepted over a TLS
+// connection authenticated with the same client certificate. DPoP-bound
+// tokens are refused, since checking DPoP proofs needs replay protection
+// shared with the issuer.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := v.Verify(r.Context(), token)
+		if errors.Is(err, ErrKeySetUnavailable) {
+			v.cfg.ErrorHandler(err)
+			http.Error(w, "token verification unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			unauthorized(w, "invalid or expired token")
+			return
+		}
+
+		if cnf := claims.Confirmation; cnf != nil {
+			if cnf.JKT != "" {
+				unauthorized(w, "dpop-bound tokens are not supported")
+				return
+			}
+			if cnf.X5TS256 != "" && !presentsCertificate(r, cnf.X5TS256) {
+				unauthorized(w, "invalid client certificate")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
+
+// NewContext returns a copy of ctx carrying the claims.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, claims)
+}
+
+// FromContext returns the claims attached by Middleware.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(ctxKey{}).(*Claims)
+	return claims, ok
+}
+
+// CurrentUser returns the claims of the request's access token. It must
+// only be called behind Middleware, and returns nil otherwise.
+func CurrentUser(r *http.Request) *Claims {
+	claims, _ := FromContext(r.Context())
+	return claims
+}
+
+// bearerToken extracts the token of a Bearer Authorization header. The
+// token must be a single non-empty field.
+func bearerToken(header string) (string, bool) {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" || strings.ContainsFunc(token, unicode.IsSpace) {
+		return "", false
+	}
+	return token, true
+}
+
+// presentsCertificate reports whether the request came over a connection
+// authenticated with the certificate of the given x5t#S256 thumbprint.
+func presentsCertificate(r *http.Request, thumbprint string) bool {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return false
+	}
+	sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+	got := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(thumbprint)) == 1
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
@@ -0,0 +1,234 @@
+// Package verifier verifies the access tokens issued by jwt-playground in
+// resource servers. Verification keys are fetched from the issuer's JWKS
+// endpoint and cached, so tokens are checked without calling the issuer.
+//
+//	v, err := verifier.New(ctx, verifier.Config{
+//		JWKSURL:   "https://auth.example.com/.well-known/jwks.json",
+//		Issuer:    "jwt-playground",
+//		Audiences: []string{"orders"},
+//	})
+//	...
+//	mux.Handle("/orders", v.Middleware(ordersHandler))
+//
+// Only signed JWTs are supported. Tokens signed with an HMAC secret cannot
+// be verified by anyone but the issuer, and opaque or encrypted tokens must
+// be checked through the issuer's introspection endpoint instead. Since
+// verification happens offline, revoked tokens are accepted until they
+// expire.
+package verifier
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultRefreshInterval    = 5 * time.Minute
+	defaultMinRefreshInterval = 30 * time.Second
+	defaultHTTPTimeout        = 10 * time.Second
+
+	// maxTokenBytes bounds the size of tokens that are parsed at all.
+	maxTokenBytes = 8 << 10
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are rejected. The
+	// underlying reason, such as jwt.ErrTokenExpired, is wrapped as well.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnknownKey is returned for tokens signed with a key that is not in
+	// the issuer's key set.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrKeySetUnavailable is returned when the key set could not be
+	// fetched at all, so no token can be verified.
+	ErrKeySetUnavailable = errors.New("key set unavailable")
+)
+
+// defaultAlgorithms are the asymmetric algorithms accepted unless
+// Config.Algorithms says otherwise.
+var defaultAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// Config configures a Verifier. JWKSURL, Issuer and Audiences are required.
+type Config struct {
+	// JWKSURL is the issuer's JWK Set, e.g.
+	// https://auth.example.com/.well-known/jwks.json.
+	JWKSURL string
+	// Issuer is the required iss claim.
+	Issuer string
+	// Audiences lists the aud values this service accepts; tokens must be
+	// issued for at least one of them.
+	Audiences []string
+	// Algorithms restricts the accepted signing algorithms. Defaults to
+	// every supported asymmetric algorithm.
+	Algorithms []string
+	// Leeway allows for clock skew in time-based checks.
+	Leeway time.Duration
+	// MaxTokenAge rejects tokens issued longer ago when set.
+	MaxTokenAge time.Duration
+	// RefreshInterval is how often the key set is fetched in the
+	// background. Defaults to 5 minutes.
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between fetches triggered by
+	// tokens with an unknown kid. Defaults to 30 seconds.
+	MinRefreshInterval time.Duration
+	// HTTPClient fetches the key set. Defaults to a client with a 10
+	// second timeout.
+	HTTPClient *http.Client
+	// ErrorHandler is called with errors no caller sees: failed background
+	// refreshes, keys of the set that are skipped, and key set failures
+	// Middleware answers with 503. It must be safe for concurrent use.
+	// Errors are dropped when it is nil.
+	ErrorHandler func(error)
+}
+
+// Verifier verifies access tokens against a remote key set.
+type Verifier struct {
+	cfg  Config
+	keys *keySet
+}
+
+// New creates a Verifier and starts refreshing the key set in the
+// background until ctx is done. The first fetch happens right away; tokens
+// verified before it completes wait for it.
+func New(ctx context.Context, cfg Config) (*Verifier, error) {
+	if cfg.JWKSURL == "" {
+		return nil, errors.New("verifier: JWKSURL is required")
+	}
+	if cfg.Issuer == "" {
+		return nil, errors.New("verifier: Issuer is required")
+	}
+	if len(cfg.Audiences) == 0 {
+		return nil, errors.New("verifier: at least one audience is required")
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = defaultAlgorithms
+	}
+	for _, alg := range cfg.Algorithms {
+		if !slices.Contains(defaultAlgorithms, alg) {
+			return nil, fmt.Errorf("verifier: unsupported algorithm %q", alg)
+		}
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	if cfg.MinRefreshInterval <= 0 {
+		cfg.MinRefreshInterval = defaultMinRefreshInterval
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(error) {}
+	}
+
+	v := &Verifier{
+		cfg: cfg,
+		keys: &keySet{
+			url:        cfg.JWKSURL,
+			client:     cfg.HTTPClient,
+			minRefresh: cfg.MinRefreshInterval,
+			onError:    cfg.ErrorHandler,
+			ready:      make(chan struct{}),
+		},
+	}
+	go v.keys.run(ctx, cfg.RefreshInterval)
+	return v, nil
+}
+
+// Verify checks an access token's signature, issuer, audience and
+// lifetime, and returns its claims. Rejected tokens produce an error
+// wrapping ErrInvalidToken; ErrKeySetUnavailable means the token could not
+// be checked at all.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if len(token) > maxTokenBytes {
+		return nil, fmt.Errorf("%w: token too large", ErrInvalidToken)
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		v.keyFunc(ctx),
+		jwt.WithValidMethods(v.cfg.Algorithms),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.cfg.Leeway),
+	)
+	if errors.Is(err, ErrKeySetUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if !slices.ContainsFunc(v.cfg.Audiences, func(aud string) bool {
+		return slices.Contains(claims.Audience, aud)
+	}) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, jwt.ErrTokenInvalidAudience)
+	}
+
+	if v.cfg.MaxTokenAge > 0 {
+		if claims.IssuedAt == nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidToken, jwt.ErrTokenRequiredClaimMissing)
+		}
+		if time.Since(claims.IssuedAt.Time) > v.cfg.MaxTokenAge+v.cfg.Leeway {
+			return nil, fmt.Errorf("%w: token exceeds maximum age", ErrInvalidToken)
+		}
+	}
+	return claims, nil
+}
+
+// keyFunc returns the key named by the token's kid, refusing keys whose
+// type or published alg does not match the token's algorithm.
+func (v *Verifier) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok || kid == "" {
+			return nil, ErrUnknownKey
+		}
+		k, err := v.keys.lookup(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		alg := t.Method.Alg()
+		if k.alg != "" && k.alg != alg {
+			return nil, fmt.Errorf("key %q is for %s, not %s", kid, k.alg, alg)
+		}
+		if !keyMatches(t.Method, k.key) {
+			return nil, fmt.Errorf("key %q cannot verify %s", kid, alg)
+		}
+		return k.key, nil
+	}
+}
+
+// keyMatches reports whether a public key belongs to the algorithm family of
+// the signing method.
+func keyMatches(method jwt.SigningMethod, key any) bool {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve.Params().BitSize == m.CurveBits
+	case *jwt.SigningMethodEd25519:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package verifier
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dyegopenha/jwt-playground/pkg/jwk"
+	"github.com/dyegopenha/jwt-playground/pkg/jwtclaims"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "api"
+)
+
+// issuer serves a JWK Set over HTTP and signs tokens with its keys. The
+// published keys can be changed while the server runs, to simulate key
+// rotation, and fetches are counted.
+type issuer struct {
+	t   testing.TB
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PrivateKey
+	published []string
+	status    int
+
+	fetches atomic.Int32
+}
+
+func newIssuer(t testing.TB) *issuer {
+	t.Helper()
+
+	i := &issuer{t: t, keys: map[string]*ecdsa.PrivateKey{}, status: http.StatusOK}
+	i.srv = httptest.NewServer(http.HandlerFunc(i.serveJWKS))
+	t.Cleanup(i.srv.Close)
+	return i
+}
+
+func (i *issuer) serveJWKS(w http.ResponseWriter, _ *http.Request) {
+	i.fetches.Add(1)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.status != http.StatusOK {
+		http.Error(w, "unavailable", i.status)
+		return
+	}
+	set := jwk.Set{Keys: []jwk.Key{}}
+	for _, kid := range i.published {
+		k, err := jwk.New(&i.keys[kid].PublicKey)
+		if err != nil {
+			i.t.Error(err)
+			return
+		}
+		k.Kid, k.Use, k.Alg = kid, "sig", "ES256"
+		set.Keys = append(set.Keys, k)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(set)
+}
+
+// publish generates a key with the given kid and adds it to the key set.
+func (i *issuer) publish(kid string) {
+	i.t.Helper()
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys[kid] = k
+	i.published = append(i.published, kid)
+}
+
+func (i *issuer) setStatus(status int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.status = status
+}
+
+// sign returns a token signed with the key kid. edit, if not nil, may
+// change the claims before signing.
+func (i *issuer) sign(kid string, edit func(*jwtclaims.Claims)) string {
+	i.t.Helper()
+
+	now := time.Now()
+	claims := jwtclaims.Claims{
+		Role: "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Subject:   "1",
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if edit != nil {
+		edit(&claims)
+	}
+
+	i.mu.Lock()
+	key := i.keys[kid]
+	i.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return signed
+}
+
+func (i *issuer) verifier(cfg Config) *Verifier {
+	i.t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i.t.Cleanup(cancel)
+
+	cfg.JWKSURL = i.srv.URL
+	cfg.Issuer = testIssuer
+	cfg.Audiences = []string{testAudience}
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = time.Hour
+	}
+	if cfg.MinRefreshInterval == 0 {
+		cfg.MinRefreshInterval = time.Hour
+	}
+	v, err := New(ctx, cfg)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	v := iss.verifier(Config{})
+
+	tests := []struct {
+		name string
+		edit func(*jwtclaims.Claims)
+		err  error
+	}{
+		{"valid", nil, nil},
+		{"wrong issuer", func(c *jwtclaims.Claims) { c.Issuer = "https://evil.example.com" }, jwt.ErrTokenInvalidIssuer},
+		{"wrong audience", func(c *jwtclaims.Claims) { c.Audience = jwt.ClaimStrings{"other"} }, jwt.ErrTokenInvalidAudience},
+		{"expired", func(c *jwtclaims.Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }, jwt.ErrTokenExpired},
+		{"no expiry", func(c *jwtclaims.Claims) { c.ExpiresAt = nil }, jwt.ErrTokenRequiredClaimMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), iss.sign("k1", tt.edit))
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("Verify() error = %v", err)
+				}
+				if claims.Subject != "1" || claims.Role != "user" {
+					t.Fatalf("Verify() claims = %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyCachesKeySet(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	v := iss.verifier(Config{})
+
+	token := iss.sign("k1", nil)
+	for range 5 {
+		if _, err := v.Verify(context.Background(), token); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+	}
+	if n := iss.fetches.Load(); n != 1 {
+		t.Fatalf("key set fetched %d times, want 1", n)
+	}
+}
+
+func TestVerifyRefreshesOnUnknownKid(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	v := iss.verifier(Config{MinRefreshInterval: time.Hour})
+
+	if _, err := v.Verify(context.Background(), iss.sign("k1", nil)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	// A rotated-in key is picked up by fetching the key set again.
+	iss.publish("k2")
+	if _, err := v.Verify(context.Background(), iss.sign("k2", nil)); err != nil {
+		t.Fatalf("Verify() with new kid error = %v", err)
+	}
+	if n := iss.fetches.Load(); n != 2 {
+		t.Fatalf("key set fetched %d times, want 2", n)
+	}
+
+	// Further unknown kids within MinRefreshInterval do not fetch again.
+	iss.publish("k3")
+	token := iss.sign("k3", nil)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("Verify() error = %v, want ErrUnknownKey", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := iss.fetches.Load(); n != 2 {
+		t.Fatalf("key set fetched %d times, want 2", n)
+	}
+}
+
+func TestVerifyKeySetUnavailable(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	iss.setStatus(http.StatusInternalServerError)
+	v := iss.verifier(Config{})
+
+	_, err := v.Verify(context.Background(), iss.sign("k1", nil))
+	if !errors.Is(err, ErrKeySetUnavailable) {
+		t.Fatalf("Verify() error = %v, want ErrKeySetUnavailable", err)
+	}
+	if errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() error = %v, must not be ErrInvalidToken", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	v := iss.verifier(Config{})
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CurrentUser(r).Subject))
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{"valid", "Bearer " + iss.sign("k1", nil), http.StatusOK, "1"},
+		{"missing", "", http.StatusUnauthorized, ""},
+		{"not bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+		{"invalid", "Bearer not-a-token", http.StatusUnauthorized, ""},
+		{
+			"expired",
+			"Bearer " + iss.sign("k1", func(c *jwtclaims.Claims) {
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			}),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"dpop bound",
+			"Bearer " + iss.sign("k1", func(c *jwtclaims.Claims) {
+				c.Confirmation = &jwtclaims.Confirmation{JKT: "thumbprint"}
+			}),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"certificate bound without certificate",
+			"Bearer " + iss.sign("k1", func(c *jwtclaims.Claims) {
+				c.Confirmation = &jwtclaims.Confirmation{X5TS256: "thumbprint"}
+			}),
+			http.StatusUnauthorized,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("missing WWW-Authenticate header")
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestMiddlewareKeySetUnavailable(t *testing.T) {
+	iss := newIssuer(t)
+	iss.publish("k1")
+	iss.setStatus(http.StatusBadGateway)
+	errs := make(chan error, 2)
+	v := iss.verifier(Config{ErrorHandler: func(err error) { errs <- err }})
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without a verified token")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+iss.sign("k1", nil))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// The failed background fetch and the request answered with 503 are
+	// both reported.
+	for range 2 {
+		if err := <-errs; !strings.Contains(err.Error(), "status 502") {
+			t.Fatalf("reported error = %v, want the failed fetch", err)
+		}
+	}
+}
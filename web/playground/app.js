@@ -0,0 +1,200 @@
+"use strict";
+
+// State of the walkthrough: the current access token and its decoded form.
+const state = {
+  accessToken: "",
+  claims: null,
+  timer: 0,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// request calls the server and records the exchange in the request log.
+async function request(method, path, { body, headers = {} } = {}) {
+  const init = { method, headers: { ...headers }, credentials: "same-origin" };
+  if (body !== undefined) {
+    init.headers["Content-Type"] = "application/json";
+    init.body = JSON.stringify(body);
+  }
+
+  const res = await fetch(path, init);
+  const text = await res.text();
+  let data = text;
+  try {
+    data = JSON.parse(text);
+  } catch {
+    // Plain-text error responses are shown as they are.
+  }
+
+  const item = document.createElement("li");
+  const status = document.createElement("span");
+  status.className = res.ok ? "status-ok" : "status-error";
+  status.textContent = `${res.status}`;
+  item.append(`${new Date().toLocaleTimeString()} ${method} ${path} → `, status);
+  $("log").prepend(item);
+
+  return { ok: res.ok, status: res.status, data };
+}
+
+function pretty(value) {
+  return typeof value === "string" ? value : JSON.stringify(value, null, 2);
+}
+
+function base64URLDecode(part) {
+  const b64 = part.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = b64 + "=".repeat((4 - (b64.length % 4)) % 4);
+  const bytes = Uint8Array.from(atob(padded), (c) => c.charCodeAt(0));
+  return JSON.parse(new TextDecoder().decode(bytes));
+}
+
+// decode reads a token's header and claims. Signed JWTs are decoded in the
+// browser; other formats need the development-only /debug/decode endpoint.
+async function decode(token) {
+  const parts = token.split(".");
+  if (parts.length === 3 && !token.startsWith("v4.")) {
+    return {
+      format: "jwt",
+      parts,
+      header: base64URLDecode(parts[0]),
+      claims: base64URLDecode(parts[1]),
+    };
+  }
+
+  const res = await request("POST", "/debug/decode", { body: { token } });
+  if (!res.ok) {
+    return null;
+  }
+  return { ...res.data, parts: null };
+}
+
+// timestamp converts a NumericDate or an RFC 3339 PASETO time to
+// milliseconds.
+function timestamp(value) {
+  if (typeof value === "number") {
+    return value * 1000;
+  }
+  return typeof value === "string" ? Date.parse(value) : NaN;
+}
+
+function renderToken(token, decoded) {
+  const raw = $("token-raw");
+  raw.replaceChildren();
+  if (decoded && decoded.parts) {
+    ["header", "payload", "signature"].forEach((name, i) => {
+      if (i > 0) {
+        raw.append(".");
+      }
+      const span = document.createElement("span");
+      span.className = `part-${name}`;
+      span.textContent = decoded.parts[i];
+      raw.append(span);
+    });
+  } else {
+    raw.textContent = token;
+  }
+
+  $("token-header").textContent = decoded
+    ? pretty(decoded.header || {})
+    : "This token format cannot be decoded outside of development.";
+  $("token-claims").textContent = decoded ? pretty(decoded.claims) : "";
+}
+
+// renderCountdown updates the access token expiry bar once per second.
+function renderCountdown() {
+  clearInterval(state.timer);
+  const claims = state.claims;
+  const bar = $("expiry-bar");
+  const text = $("expiry-text");
+  if (!claims || !claims.exp) {
+    bar.style.width = "0";
+    text.textContent = "unknown expiry";
+    return;
+  }
+
+  const exp = timestamp(claims.exp);
+  const iat = timestamp(claims.iat) || Date.now();
+  const tick = () => {
+    const left = exp - Date.now();
+    const ratio = Math.max(0, Math.min(1, left / (exp - iat)));
+    bar.style.width = `${ratio * 100}%`;
+    bar.className = left <= 0 ? "expired" : ratio < 0.25 ? "warn" : "";
+    text.textContent =
+      left <= 0
+        ? "expired, refresh to get a new one"
+        : `expires in ${Math.ceil(left / 1000)}s`;
+    if (left <= 0) {
+      clearInterval(state.timer);
+    }
+  };
+  tick();
+  state.timer = setInterval(tick, 1000);
+}
+
+// refreshCookie returns the refresh token cookie when it is readable from
+// JavaScript.
+function refreshCookie() {
+  const cookie = document.cookie
+    .split("; ")
+    .find((c) => c.startsWith("refresh_token="));
+  return cookie ? cookie.slice("refresh_token=".length) : "(not readable from JavaScript)";
+}
+
+function shorten(value) {
+  return value.length > 24 ? `${value.slice(0, 12)}…${value.slice(-8)}` : value;
+}
+
+function recordRotation() {
+  const row = document.createElement("tr");
+  const jti = state.claims && state.claims.jti ? state.claims.jti : "";
+  [new Date().toLocaleTimeString(), shorten(refreshCookie()), jti].forEach((value) => {
+    const cell = document.createElement("td");
+    const code = document.createElement("code");
+    code.textContent = value;
+    cell.append(code);
+    row.append(cell);
+  });
+  $("rotations").querySelector("tbody").prepend(row);
+}
+
+async function useAccessToken(token) {
+  state.accessToken = token;
+  const decoded = await decode(token);
+  state.claims = decoded ? decoded.claims : null;
+  renderToken(token, decoded);
+  renderCountdown();
+  for (const id of ["step-token", "step-profile", "step-refresh"]) {
+    $(id).hidden = false;
+  }
+}
+
+$("sign-in-form").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const form = new FormData(event.target);
+  const res = await request("POST", "/sign-in", {
+    body: { email: form.get("email"), password: form.get("password") },
+  });
+  if (!res.ok) {
+    alert(`Sign in failed: ${pretty(res.data)}`);
+    return;
+  }
+  await useAccessToken(res.data.access_token);
+  $("rotations").querySelector("tbody").replaceChildren();
+  recordRotation();
+});
+
+$("profile-button").addEventListener("click", async () => {
+  const res = await request("GET", "/", {
+    headers: { Authorization: `Bearer ${state.accessToken}` },
+  });
+  $("profile-result").textContent = `${res.status}\n${pretty(res.data)}`;
+});
+
+$("refresh-button").addEventListener("click", async () => {
+  const res = await request("POST", "/refresh");
+  if (!res.ok) {
+    alert(`Refresh failed: ${pretty(res.data)}`);
+    return;
+  }
+  await useAccessToken(res.data.access_token);
+  recordRotation();
+});